@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -17,6 +18,10 @@ type Config struct {
 	JWT     JWTConfig
 	Common  CommonConfig
 	Storage StorageConfig
+	OIDC    OIDCConfig
+	LDAP    LDAPConfig
+	Mail    MailConfig
+	Webhook WebhookConfig
 }
 
 type SSLConfig struct {
@@ -36,26 +41,115 @@ type DBConfig struct {
 }
 
 type JWTConfig struct {
-	TokenSecret string
+	TokenSecret     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
 }
 
 type CommonConfig struct {
+	PublicURL         string // externally reachable base URL, used in links we hand out
+	BootstrapAdmin    string // email promoted to admin at startup while no admin exists
 	AllowRegistration bool
 	TryLoginTimes     int
 	DiffNums          int
 	TempDir           string // Renamed from DataDir and moved here
+	LoginGuard        LoginGuardConfig
+	PasswordResetTTL  time.Duration
+	// RequireEmailVerification keeps self-registered accounts from logging in
+	// until they open the link mailed to them.
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	InviteTTL                time.Duration
+	// MaxPackageSizeMB caps the uncompressed size of an uploaded package.
+	MaxPackageSizeMB int
+	// Resumable uploads are assembled in TempDir; abandoned ones are
+	// removed UploadSessionTTL after they were started.
+	UploadSessionTTL time.Duration
+	MaxUploadChunkMB int
+}
+
+// LoginGuardConfig controls lockouts after TryLoginTimes failed logins.
+type LoginGuardConfig struct {
+	Store       string // "database" or "memory"
+	IPTryTimes  int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// WebhookConfig controls delivery of outbound webhooks. Failed deliveries are
+// retried with exponential backoff starting at BaseBackoff.
+type WebhookConfig struct {
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	PollInterval time.Duration
+	// AllowedNetworks lists comma separated CIDRs of loopback, private or
+	// link-local addresses webhooks may still be delivered to.
+	AllowedNetworks string
+}
+
+// OIDCConfig enables single sign-on through an OpenID Connect provider when IssuerURL is set.
+type OIDCConfig struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        string
+	EmailClaim    string
+	AutoProvision bool
+}
+
+// LDAPConfig enables directory logins when URL is set. SearchFilter may use
+// {account} for the (escaped) login name; GroupRoles maps directory groups to
+// roles as "role:groupDN;role:groupDN".
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	SearchBase         string
+	SearchFilter       string
+	UsernameAttribute  string
+	EmailAttribute     string
+	GroupAttribute     string
+	GroupRoles         string
+}
+
+// MailConfig configures outgoing mail. With no SMTPHost, mails are only logged.
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
 }
 
 type StorageConfig struct {
-	Type  string
-	Local LocalConfig
-	S3    S3Config
+	Type string
+	// DownloadURLTTL is how long the package URLs handed out by update checks
+	// stay valid; 0 hands out permanent public URLs. Local storage signs by
+	// default, other storages only when it is set.
+	DownloadURLTTL time.Duration
+	// CDNBaseURL replaces the storage's own URL in download URLs. Download
+	// URLs served from a CDN are never signed, whatever DownloadURLTTL is.
+	CDNBaseURL string
+	// Unreferenced objects and temp files are deleted every GCInterval (0
+	// disables the job) once they are older than GCGrace.
+	GCInterval time.Duration
+	GCGrace    time.Duration
+	Local      LocalConfig
+	S3         S3Config
+	GCS        GCSConfig
+	Azure      AzureConfig
 }
 
 type LocalConfig struct {
 	StorageDir  string
 	DownloadUrl string
 	Public      string
+	// DownloadSecret signs local download URLs, TOKEN_SECRET when empty.
+	DownloadSecret string
 }
 
 type S3Config struct {
@@ -64,6 +158,47 @@ type S3Config struct {
 	Region          string
 	BucketName      string
 	DownloadUrl     string
+	// Endpoint points the client at an S3 compatible store such as MinIO,
+	// Ceph, R2 or Wasabi.
+	Endpoint string
+	// PathStyle addresses buckets as endpoint/bucket instead of
+	// bucket.endpoint; defaults to on when Endpoint is set.
+	PathStyle bool
+	// Without an access key the default AWS credential chain is used
+	// (environment, shared config, instance or task role).
+	SessionToken string
+	// SSE is the server-side encryption of stored objects: "", "AES256" or
+	// "aws:kms" with the optional SSEKMSKeyID.
+	SSE         string
+	SSEKMSKeyID string
+	// Prefix is prepended to every object key, to share a bucket.
+	Prefix string
+}
+
+type GCSConfig struct {
+	BucketName string
+	Prefix     string
+	// CredentialsFile is a service account key; without it the application
+	// default credentials are used.
+	CredentialsFile string
+	// DownloadUrl replaces https://storage.googleapis.com/<bucket> in public
+	// download URLs, e.g. for a CDN in front of the bucket.
+	DownloadUrl string
+}
+
+// AzureConfig authenticates with ConnectionString, AccountKey or SASToken,
+// in that order of preference.
+type AzureConfig struct {
+	AccountName      string
+	AccountKey       string
+	ConnectionString string
+	SASToken         string
+	Container        string
+	// Endpoint is the blob service URL, https://<account>.blob.core.windows.net
+	// by default, or e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+	Endpoint    string
+	Prefix      string
+	DownloadUrl string
 }
 
 func LoadConfig() Config {
@@ -85,20 +220,78 @@ func LoadConfig() Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			TokenSecret: getEnv("TOKEN_SECRET", "INSERT_RANDOM_TOKEN_KEY"),
+			TokenSecret:     getEnv("TOKEN_SECRET", "INSERT_RANDOM_TOKEN_KEY"),
+			AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
 		},
 		Common: CommonConfig{
+			PublicURL:         getEnv("PUBLIC_URL", "http://127.0.0.1:3000"),
+			BootstrapAdmin:    getEnv("BOOTSTRAP_ADMIN_EMAIL", ""),
 			AllowRegistration: getEnvBool("ALLOW_REGISTRATION", false),
 			TryLoginTimes:     getEnvInt("TRY_LOGIN_TIMES", 4),
 			DiffNums:          getEnvInt("DIFF_NUMS", 3),
 			TempDir:           getEnv("TEMP_DIR", "/tmp"), // Added TempDir
+			LoginGuard: LoginGuardConfig{
+				Store:       getEnv("LOGIN_GUARD_STORE", "database"),
+				IPTryTimes:  getEnvInt("TRY_LOGIN_TIMES_PER_IP", 20),
+				BaseLockout: getEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+				MaxLockout:  getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+			},
+			PasswordResetTTL:         getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+			RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+			EmailVerificationTTL:     getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			InviteTTL:                getEnvDuration("INVITE_TTL", 7*24*time.Hour),
+			MaxPackageSizeMB:         getEnvInt("PACKAGE_MAX_SIZE_MB", 512),
+			UploadSessionTTL:         getEnvDuration("UPLOAD_SESSION_TTL", 24*time.Hour),
+			MaxUploadChunkMB:         getEnvInt("UPLOAD_CHUNK_MAX_MB", 64),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:     getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:      getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:   getEnv("OIDC_REDIRECT_URL", "http://127.0.0.1:3000/auth/oidc/callback"),
+			Scopes:        getEnv("OIDC_SCOPES", "openid email profile"),
+			EmailClaim:    getEnv("OIDC_EMAIL_CLAIM", "email"),
+			AutoProvision: getEnvBool("OIDC_AUTO_PROVISION", false),
+		},
+		LDAP: LDAPConfig{
+			URL:                getEnv("LDAP_URL", ""),
+			StartTLS:           getEnvBool("LDAP_START_TLS", false),
+			InsecureSkipVerify: getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+			BindDN:             getEnv("LDAP_BIND_DN", ""),
+			BindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+			SearchBase:         getEnv("LDAP_SEARCH_BASE", ""),
+			SearchFilter:       getEnv("LDAP_SEARCH_FILTER", "(|(uid={account})(mail={account}))"),
+			UsernameAttribute:  getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+			EmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			GroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+			GroupRoles:         getEnv("LDAP_GROUP_ROLES", ""),
+		},
+		Mail: MailConfig{
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "25"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("SMTP_FROM", "CodePushServer <no-reply@localhost>"),
+		},
+		Webhook: WebhookConfig{
+			Timeout:         getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:     getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			BaseBackoff:     getEnvDuration("WEBHOOK_BACKOFF", 30*time.Second),
+			PollInterval:    getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+			AllowedNetworks: getEnv("WEBHOOK_ALLOWED_NETWORKS", ""),
 		},
 		Storage: StorageConfig{
-			Type: getEnv("STORAGE_TYPE", "local"),
+			Type:           getEnv("STORAGE_TYPE", "local"),
+			DownloadURLTTL: getEnvDuration("DOWNLOAD_URL_TTL", defaultDownloadURLTTL(getEnv("STORAGE_TYPE", "local"))),
+			CDNBaseURL:     getEnv("CDN_BASE_URL", ""),
+			GCInterval:     getEnvDuration("STORAGE_GC_INTERVAL", 0),
+			GCGrace:        getEnvDuration("STORAGE_GC_GRACE", 7*24*time.Hour),
 			Local: LocalConfig{
-				StorageDir:  getEnv("LOCAL_STORAGE_DIR", "/tmp/codepush"),
-				DownloadUrl: getEnv("LOCAL_DOWNLOAD_URL", "http://127.0.0.1:3000/download"),
-				Public:      getEnv("LOCAL_PUBLIC", "/download"),
+				StorageDir:     getEnv("LOCAL_STORAGE_DIR", "/tmp/codepush"),
+				DownloadUrl:    getEnv("LOCAL_DOWNLOAD_URL", "http://127.0.0.1:3000/download"),
+				Public:         getEnv("LOCAL_PUBLIC", "/download"),
+				DownloadSecret: getEnv("LOCAL_DOWNLOAD_SECRET", ""),
 			},
 			S3: S3Config{
 				AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
@@ -106,11 +299,45 @@ func LoadConfig() Config {
 				Region:          getEnv("AWS_REGION", "us-east-1"),
 				BucketName:      getEnv("AWS_BUCKET_NAME", ""),
 				DownloadUrl:     getEnv("AWS_DOWNLOAD_URL", ""),
+				Endpoint:        getEnv("AWS_ENDPOINT", ""),
+				PathStyle:       getEnvBool("AWS_S3_PATH_STYLE", getEnv("AWS_ENDPOINT", "") != ""),
+				SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+				SSE:             getEnv("AWS_S3_SSE", ""),
+				SSEKMSKeyID:     getEnv("AWS_S3_SSE_KMS_KEY_ID", ""),
+				Prefix:          getEnv("AWS_S3_PREFIX", ""),
+			},
+			GCS: GCSConfig{
+				BucketName:      getEnv("GCS_BUCKET_NAME", ""),
+				Prefix:          getEnv("GCS_PREFIX", ""),
+				CredentialsFile: getEnv("GCS_CREDENTIALS_FILE", ""),
+				DownloadUrl:     getEnv("GCS_DOWNLOAD_URL", ""),
+			},
+			Azure: AzureConfig{
+				AccountName:      getEnv("AZURE_STORAGE_ACCOUNT", ""),
+				AccountKey:       getEnv("AZURE_STORAGE_KEY", ""),
+				ConnectionString: getEnv("AZURE_STORAGE_CONNECTION_STRING", ""),
+				SASToken:         getEnv("AZURE_STORAGE_SAS_TOKEN", ""),
+				Container:        getEnv("AZURE_STORAGE_CONTAINER", ""),
+				Endpoint:         getEnv("AZURE_STORAGE_ENDPOINT", ""),
+				Prefix:           getEnv("AZURE_STORAGE_PREFIX", ""),
+				DownloadUrl:      getEnv("AZURE_DOWNLOAD_URL", ""),
 			},
 		},
 	}
 }
 
+// defaultDownloadURLTTL is an hour for local storage, whose download route
+// would serve every package to anyone otherwise; unrecognized types are
+// local storage too.
+func defaultDownloadURLTTL(storageType string) time.Duration {
+	switch storageType {
+	case "s3", "gcs", "azure":
+		return 0
+	default:
+		return time.Hour
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists && value != "" {
 		return value
@@ -134,6 +361,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func InitDB(dbConfig *DBConfig) *gorm.DB {
 	dsn := "host=" + dbConfig.Host + " user=" + dbConfig.Username + " password=" + dbConfig.Password + " dbname=" + dbConfig.Database + " port=" + dbConfig.Port + " sslmode=" + dbConfig.SSLMode + " TimeZone=" + dbConfig.TimeZone
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
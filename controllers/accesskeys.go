@@ -8,12 +8,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/venkatvghub/code-push-server-go/middleware"
 	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
 	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
 
 type AccessKeysController struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	AuditSvc *services.AuditService
 }
 
 func (ctrl *AccessKeysController) CreateAccessKey(c *gin.Context) {
@@ -56,9 +58,36 @@ func (ctrl *AccessKeysController) CreateAccessKey(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAccessKeyCreate, TargetType: "access_key", Target: token.Name,
+		After: gin.H{"createdBy": token.CreatedBy, "description": token.Description, "expires": token.ExpiresAt.Time.UnixMilli()},
+	})
+
 	c.JSON(http.StatusOK, gin.H{"accessKey": gin.H{"name": newAccessKey}})
 }
 
+func (ctrl *AccessKeysController) DeleteAccessKey(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	name := c.Param("name")
+
+	var token models.UserToken
+	if err := ctrl.DB.Where("uid = ? AND name = ?", uid, name).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access key not found"})
+		return
+	}
+	if err := ctrl.DB.Delete(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete access key"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAccessKeyDelete, TargetType: "access_key", Target: token.Name,
+		Before: gin.H{"createdBy": token.CreatedBy, "description": token.Description},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"friendlyName": token.Name})
+}
+
 func (ctrl *AccessKeysController) SetupRoutes(r *gin.Engine) {
 	accessKeys := r.Group("/accessKeys")
 	accessKeys.Use(middleware.AuthMiddleware(ctrl.DB))
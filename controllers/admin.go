@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type AdminController struct {
+	DB           *gorm.DB
+	AdminSvc     *services.AdminService
+	OrgSvc       *services.OrgService
+	SessionSvc   *services.SessionService
+	TwoFactorSvc *services.TwoFactorService
+	ResetSvc     *services.PasswordResetService
+	LoginGuard   *services.LoginGuard
+	AuditSvc     *services.AuditService
+}
+
+func (ctrl *AdminController) ListUsers(c *gin.Context) {
+	page, pageSize := pagination(c)
+	users, total, err := ctrl.AdminSvc.ListUsers(c.Query("q"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	result := make([]gin.H, len(users))
+	for i := range users {
+		result[i] = adminUserJSON(&users[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"users": result, "total": total, "page": page, "pageSize": pageSize})
+}
+
+func (ctrl *AdminController) GetUser(c *gin.Context) {
+	target, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+
+	var collaborators []models.Collaborator
+	ctrl.DB.Where("uid = ?", target.ID).Find(&collaborators)
+	apps := make([]gin.H, 0, len(collaborators))
+	for _, col := range collaborators {
+		var app models.App
+		if err := ctrl.DB.First(&app, col.AppID).Error; err == nil {
+			apps = append(apps, gin.H{"id": app.ID, "name": ctrl.appNames([]models.App{app})[app.ID], "permission": col.Roles})
+		}
+	}
+	orgs, _ := ctrl.OrgSvc.ListOrgs(target.ID)
+	orgsJSON := make([]gin.H, len(orgs))
+	for i, org := range orgs {
+		orgsJSON[i] = gin.H{"name": org.Name, "role": org.Role}
+	}
+
+	result := adminUserJSON(target)
+	result["apps"] = apps
+	result["organizations"] = orgsJSON
+	c.JSON(http.StatusOK, gin.H{"user": result})
+}
+
+func (ctrl *AdminController) UpdateUser(c *gin.Context) {
+	user, _ := c.Get("user")
+	target, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Disabled *bool `json:"disabled"`
+		Admin    *bool `json:"admin"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Disabled == nil && input.Admin == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	// Keeps an admin from locking everyone out by demoting or disabling themselves.
+	if target.ID == user.(models.User).ID {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "You cannot change your own admin or disabled flag"})
+		return
+	}
+
+	fields := map[string]interface{}{}
+	if input.Disabled != nil {
+		fields["is_disabled"] = utils.BoolToUint8(*input.Disabled)
+	}
+	if input.Admin != nil {
+		fields["is_admin"] = utils.BoolToUint8(*input.Admin)
+	}
+	if err := ctrl.AdminSvc.UpdateUser(target.ID, fields); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAdminUserUpdate, TargetType: "user", Target: target.Email,
+		Before: gin.H{"disabled": target.IsDisabled == 1, "admin": target.IsAdmin == 1}, After: input,
+	})
+	if input.Disabled != nil && *input.Disabled {
+		if err := ctrl.SessionSvc.RevokeAll(target.ID); err != nil {
+			log.Printf("Failed to revoke sessions of disabled user %d: %v", target.ID, err)
+		}
+	}
+
+	target, _ = ctrl.AdminSvc.FindUser(target.ID)
+	c.JSON(http.StatusOK, gin.H{"user": adminUserJSON(target)})
+}
+
+func (ctrl *AdminController) ForcePasswordReset(c *gin.Context) {
+	target, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+	if !isLocalAccount(target) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Password is managed by the " + target.AuthSource + " identity provider"})
+		return
+	}
+
+	if err := ctrl.ResetSvc.Force(target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditAdminPasswordReset, TargetType: "user", Target: target.Email})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (ctrl *AdminController) ResetTwoFactor(c *gin.Context) {
+	target, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.TwoFactorSvc.Disable(target.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditAdminTwoFactorReset, TargetType: "user", Target: target.Email})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (ctrl *AdminController) UnlockUser(c *gin.Context) {
+	target, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.LoginGuard.Unlock(services.AccountLoginKey(target.Email)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditAdminUnlock, TargetType: "user", Target: target.Email})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (ctrl *AdminController) ListApps(c *gin.Context) {
+	page, pageSize := pagination(c)
+	apps, total, err := ctrl.AdminSvc.ListApps(c.Query("q"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch apps"})
+		return
+	}
+
+	uids := make([]uint64, len(apps))
+	for i, app := range apps {
+		uids[i] = app.UID
+	}
+	owners, err := ctrl.AdminSvc.FindUsers(uids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch apps"})
+		return
+	}
+
+	names := ctrl.appNames(apps)
+	result := make([]gin.H, len(apps))
+	for i, app := range apps {
+		result[i] = gin.H{
+			"id":               app.ID,
+			"name":             names[app.ID],
+			"owner":            owners[app.UID].Email,
+			"os":               app.OS,
+			"platform":         app.Platform,
+			"requireTwoFactor": app.RequireTwoFactor == 1,
+			"createdTime":      app.CreatedAt.UnixMilli(),
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"apps": result, "total": total, "page": page, "pageSize": pageSize})
+}
+
+func (ctrl *AdminController) GetApp(c *gin.Context) {
+	appID, err := strconv.ParseUint(c.Param("appID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app id"})
+		return
+	}
+	var app models.App
+	if err := ctrl.DB.First(&app, appID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	var collaborators []models.Collaborator
+	ctrl.DB.Where("app_id = ?", app.ID).Find(&collaborators)
+	uids := make([]uint64, len(collaborators))
+	for i, col := range collaborators {
+		uids[i] = col.UID
+	}
+	users, err := ctrl.AdminSvc.FindUsers(uids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collaborators"})
+		return
+	}
+	collaboratorsJSON := make(map[string]gin.H)
+	for _, col := range collaborators {
+		if u, ok := users[col.UID]; ok {
+			collaboratorsJSON[u.Email] = gin.H{"permission": col.Roles}
+		}
+	}
+
+	var deployments []models.Deployment
+	ctrl.DB.Where("app_id = ?", app.ID).Find(&deployments)
+	deploymentsJSON := make([]gin.H, len(deployments))
+	for i, d := range deployments {
+		deploymentsJSON[i] = gin.H{
+			"name":        d.Name,
+			"key":         d.DeploymentKey,
+			"createdTime": d.CreatedAt.UnixMilli(),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"app": gin.H{
+		"id":               app.ID,
+		"name":             ctrl.appNames([]models.App{app})[app.ID],
+		"owner":            users[app.UID].Email,
+		"os":               app.OS,
+		"platform":         app.Platform,
+		"requireTwoFactor": app.RequireTwoFactor == 1,
+		"createdTime":      app.CreatedAt.UnixMilli(),
+		"collaborators":    collaboratorsJSON,
+		"deployments":      deploymentsJSON,
+	}})
+}
+
+func (ctrl *AdminController) findUser(c *gin.Context) (*models.User, bool) {
+	uid, err := strconv.ParseUint(c.Param("uid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return nil, false
+	}
+	target, err := ctrl.AdminSvc.FindUser(uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return target, true
+}
+
+// appNames returns the "org/app" names of apps, keyed by app ID.
+func (ctrl *AdminController) appNames(apps []models.App) map[uint]string {
+	orgIDs := make([]uint, 0, len(apps))
+	for _, app := range apps {
+		if app.OrgID != 0 {
+			orgIDs = append(orgIDs, app.OrgID)
+		}
+	}
+	orgNames := map[uint]string{}
+	if len(orgIDs) > 0 {
+		orgNames, _ = ctrl.OrgSvc.Names(orgIDs)
+	}
+	names := make(map[uint]string, len(apps))
+	for _, app := range apps {
+		names[app.ID] = services.AppFullName(orgNames[app.OrgID], app.Name)
+	}
+	return names
+}
+
+func adminUserJSON(u *models.User) gin.H {
+	return gin.H{
+		"id":                  u.ID,
+		"email":               u.Email,
+		"username":            u.Username,
+		"authSource":          u.AuthSource,
+		"isAdmin":             u.IsAdmin == 1,
+		"isDisabled":          u.IsDisabled == 1,
+		"twoFactorEnabled":    u.TOTPEnabled == 1,
+		"pendingVerification": u.PendingVerification == 1,
+		"createdTime":         u.CreatedAt.UnixMilli(),
+	}
+}
+
+// pagination reads the page and pageSize query parameters, clamped to sane values.
+func pagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -16,9 +19,13 @@ import (
 )
 
 type AppsController struct {
-	DB      *gorm.DB
-	AppSvc  *services.AppService
-	AcctSvc *services.AccountService
+	DB         *gorm.DB
+	AppSvc     *services.AppService
+	AcctSvc    *services.AccountService
+	OrgSvc     *services.OrgService
+	InviteSvc  *services.InviteService
+	AuditSvc   *services.AuditService
+	WebhookSvc *services.WebhookService
 }
 
 func (ctrl *AppsController) AddApp(c *gin.Context) {
@@ -36,11 +43,27 @@ func (ctrl *AppsController) AddApp(c *gin.Context) {
 		return
 	}
 
-	_, err := ctrl.AppSvc.AddApp(uid, input.Name, input.OS, input.Platform)
+	// "org/app" creates the app inside an organization the caller administers.
+	var orgID uint
+	orgName, name := services.SplitAppName(input.Name)
+	if orgName != "" {
+		org, _, err := ctrl.OrgSvc.MemberCan(uid, orgName, models.OrgRoleOwner, models.OrgRoleAdmin)
+		if err != nil {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+			return
+		}
+		orgID = org.ID
+	}
+
+	app, err := ctrl.AppSvc.AddApp(uid, orgID, name, input.OS, input.Platform)
 	if err != nil {
 		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAppCreate, AppID: app.ID, TargetType: "app", Target: input.Name,
+		After: gin.H{"name": input.Name, "os": input.OS, "platform": input.Platform},
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"app": gin.H{
@@ -67,6 +90,9 @@ func (ctrl *AppsController) DeleteApp(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete app"})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAppDelete, AppID: collaborator.AppID, TargetType: "app", Target: appName,
+	})
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -90,15 +116,107 @@ func (ctrl *AppsController) RenameApp(c *gin.Context) {
 		return
 	}
 
-	if existingApp, _ := ctrl.AppSvc.FindAppByName(uid, input.Name); existingApp != nil {
+	// Renames stay within the owner; moving into an org is a transfer.
+	orgName, _ := services.SplitAppName(appName)
+	newOrgName, newName := services.SplitAppName(input.Name)
+	if newOrgName != "" && newOrgName != orgName {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Use transfer to move an app to another organization"})
+		return
+	}
+	if newName == "" || strings.Contains(newName, "/") {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "invalid app name"})
+		return
+	}
+	if existingApp, _ := ctrl.AppSvc.FindAppByName(uid, services.AppFullName(orgName, newName)); existingApp != nil {
 		c.JSON(http.StatusNotAcceptable, gin.H{"error": input.Name + " exists"})
 		return
 	}
 
-	if err := ctrl.DB.Model(&models.App{}).Where("id = ?", collaborator.AppID).Update("name", input.Name).Error; err != nil {
+	if err := ctrl.DB.Model(&models.App{}).Where("id = ?", collaborator.AppID).Update("name", newName).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename app"})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAppRename, AppID: collaborator.AppID, TargetType: "app", Target: appName,
+		Before: gin.H{"name": appName}, After: gin.H{"name": services.AppFullName(orgName, newName)},
+	})
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// TransferApp moves an app into an organization. The caller must own the app
+// and administer the organization.
+func (ctrl *AppsController) TransferApp(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	appName := strings.TrimSpace(c.Param("appName"))
+	orgName := strings.TrimSpace(c.Param("orgName"))
+
+	collaborator, err := ctrl.AcctSvc.OwnerCan(uid, appName)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	org, _, err := ctrl.OrgSvc.MemberCan(uid, orgName, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, name := services.SplitAppName(appName)
+	if existingApp, _ := ctrl.AppSvc.FindAppByName(uid, services.AppFullName(org.Name, name)); existingApp != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": services.AppFullName(org.Name, name) + " exists"})
+		return
+	}
+
+	if err := ctrl.DB.Model(&models.App{}).Where("id = ?", collaborator.AppID).Update("org_id", org.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer app"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAppTransfer, AppID: collaborator.AppID, TargetType: "app", Target: appName,
+		Before: gin.H{"name": appName}, After: gin.H{"name": services.AppFullName(org.Name, name)},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"app": gin.H{"name": services.AppFullName(org.Name, name)}})
+}
+
+func (ctrl *AppsController) SetTwoFactorRequirement(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+	appName := strings.TrimSpace(c.Param("appName"))
+
+	var input struct {
+		Required *bool `json:"required" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	collaborator, err := ctrl.AcctSvc.OwnerCan(userModel.ID, appName)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Owners must be enrolled themselves, or they would lock themselves out.
+	if *input.Required && userModel.TOTPEnabled != 1 {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Enable two-factor authentication for your account first"})
+		return
+	}
+
+	var app models.App
+	ctrl.DB.Select("id, require_two_factor").First(&app, collaborator.AppID)
+	if err := ctrl.DB.Model(&models.App{}).Where("id = ?", collaborator.AppID).
+		Update("require_two_factor", utils.BoolToUint8(*input.Required)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update app"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditAppTwoFactor, AppID: collaborator.AppID, TargetType: "app", Target: appName,
+		Before: gin.H{"requireTwoFactor": app.RequireTwoFactor == 1}, After: gin.H{"requireTwoFactor": *input.Required},
+	})
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -131,6 +249,27 @@ func (ctrl *AppsController) ListCollaborators(c *gin.Context) {
 		}
 	}
 
+	// Organization members collaborate through their org role.
+	var app models.App
+	if err := ctrl.DB.Select("id, org_id").First(&app, collaborator.AppID).Error; err == nil && app.OrgID != 0 {
+		members, _ := ctrl.OrgSvc.Members(app.OrgID)
+		for _, member := range members {
+			var userModel models.User
+			if err := ctrl.DB.Where("id = ?", member.UID).First(&userModel).Error; err != nil {
+				continue
+			}
+			role := services.AppRoleForOrgRole(member.Role)
+			if existing, ok := result[userModel.Email]; ok && (existing["permission"] == "Owner" || role != "Owner") {
+				continue
+			}
+			result[userModel.Email] = gin.H{
+				"permission":       role,
+				"isCurrentAccount": member.UID == uid,
+				"inheritedFrom":    "organization",
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"collaborators": result})
 }
 
@@ -148,7 +287,22 @@ func (ctrl *AppsController) AddCollaborator(c *gin.Context) {
 
 	targetUser, err := ctrl.AcctSvc.FindUserByEmail(email)
 	if err != nil {
-		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		// No account yet: invite the address instead, so teams can grow
+		// while self-registration stays closed.
+		inviter := user.(models.User)
+		if err := ctrl.InviteSvc.Invite(collaborator.AppID, appName, email, "Collaborator", &inviter); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation"})
+			return
+		}
+		recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+			Action: models.AuditCollaboratorInvite, AppID: collaborator.AppID, TargetType: "collaborator", Target: email,
+			After: gin.H{"permission": "Collaborator"},
+		})
+		ctrl.WebhookSvc.Emit(collaborator.AppID, services.WebhookPayload{
+			Event: models.WebhookEventCollaborator, App: appName, Actor: inviter.Email,
+			Collaborator: &services.WebhookCollaborator{Email: email, Permission: "Collaborator", Change: "invited"},
+		})
+		c.JSON(http.StatusOK, gin.H{"invited": true})
 		return
 	}
 
@@ -160,6 +314,14 @@ func (ctrl *AppsController) AddCollaborator(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add collaborator"})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditCollaboratorAdd, AppID: collaborator.AppID, TargetType: "collaborator", Target: email,
+		After: gin.H{"permission": "Collaborator"},
+	})
+	ctrl.WebhookSvc.Emit(collaborator.AppID, services.WebhookPayload{
+		Event: models.WebhookEventCollaborator, App: appName, Actor: user.(models.User).Email,
+		Collaborator: &services.WebhookCollaborator{Email: email, Permission: "Collaborator", Change: "added"},
+	})
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -188,6 +350,9 @@ func (ctrl *AppsController) AddDeployment(c *gin.Context) {
 		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditDeploymentCreate, AppID: collaborator.AppID, TargetType: "deployment", Target: deployment.Name,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"deployment": gin.H{
@@ -215,43 +380,149 @@ func (ctrl *AppsController) ReleasePackage(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("file")
+	releaseNotes, err := services.ParseReleaseNotes(c.PostForm("releaseNotes"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if !strings.HasSuffix(file.Filename, ".zip") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type: must be zip"})
+	zipPath, status, err := ctrl.assemblePackage(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
+	ctrl.release(c, collaborator.AppID, appName, deployment, zipPath, releaseNotes)
+}
 
-	storage := utils.NewStorage()
-	cfg := config.LoadConfig()
-	tempFilePath := cfg.Common.TempDir + "/" + file.Filename
-	if err := c.SaveUploadedFile(file, tempFilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file temporarily"})
-		return
-	}
+// release publishes the package zip at zipPath to deployment with the
+// description and isMandatory form fields, and removes the zip. It reports
+// whether the package was released.
+func (ctrl *AppsController) release(c *gin.Context, appID uint, appName string, deployment *models.Deployment, zipPath string, releaseNotes []services.ReleaseNote) bool {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
 
-	key := utils.RandToken(10) + "_" + file.Filename
-	if err := storage.UploadFile(tempFilePath, key); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file to storage"})
-		os.Remove(tempFilePath)
-		return
-	}
+	defer os.Remove(zipPath)
 
-	defer os.Remove(tempFilePath)
+	pkg, err := ctrl.AppSvc.ReleasePackage(c.Request.Context(), appID, deployment.ID, zipPath, c.PostForm("description"), uid, c.PostForm("isMandatory") == "true", releaseNotes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	ctrl.released(c, appID, appName, deployment, pkg)
+	return true
+}
 
-	_, err = ctrl.AppSvc.ReleasePackage(collaborator.AppID, deployment.ID, tempFilePath, c.PostForm("description"), uid, c.PostForm("isMandatory") == "true")
+// releaseStored publishes a package zip already stored as blob, like
+// release does for a zip file.
+func (ctrl *AppsController) releaseStored(c *gin.Context, appID uint, appName string, deployment *models.Deployment, blob *models.Blob, releaseNotes []services.ReleaseNote) bool {
+	user, _ := c.Get("user")
+	pkg, err := ctrl.AppSvc.ReleaseStoredPackage(appID, deployment.ID, blob.Key, blob.Size, blob.Hash,
+		c.PostForm("description"), user.(models.User).ID, c.PostForm("isMandatory") == "true", releaseNotes)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return false
 	}
+	ctrl.released(c, appID, appName, deployment, pkg)
+	return true
+}
+
+// released finishes a release once its package exists: it records the
+// release and notifies webhooks.
+func (ctrl *AppsController) released(c *gin.Context, appID uint, appName string, deployment *models.Deployment, pkg *models.Package) {
+	user, _ := c.Get("user")
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditRelease, AppID: appID, TargetType: "deployment", Target: deployment.Name,
+		After: auditPackage(pkg),
+	})
+	ctrl.WebhookSvc.Emit(appID, services.WebhookPayload{
+		Event: models.WebhookEventRelease, App: appName, Deployment: deployment.Name, Actor: user.(models.User).Email,
+		Package: services.NewWebhookPackage(pkg, user.(models.User).Email),
+	})
 
 	c.JSON(http.StatusOK, gin.H{"msg": "succeed"})
 }
 
+// assemblePackage turns the upload of a release into a zip in the canonical
+// package layout and returns its path. The upload is either a "file" holding
+// a zip or tar.gz, or the files of a directory as "files", with their paths
+// relative to the directory in "paths" (browsers drop directories from file
+// names).
+func (ctrl *AppsController) assemblePackage(c *gin.Context) (string, int, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", http.StatusBadRequest, errors.New("No file uploaded")
+	}
+
+	if files := form.File["file"]; len(files) == 1 && len(form.File["files"]) == 0 {
+		upload := filepath.Join(utils.Config.Common.TempDir, utils.RandToken(10)+"_upload")
+		if err := c.SaveUploadedFile(files[0], upload); err != nil {
+			return "", http.StatusInternalServerError, errors.New("Failed to save file temporarily")
+		}
+		defer os.Remove(upload)
+		return packageFromArchive(upload, files[0].Filename)
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		return "", http.StatusBadRequest, errors.New("No file uploaded")
+	}
+	paths := form.Value["paths"]
+	if len(paths) != 0 && len(paths) != len(files) {
+		return "", http.StatusBadRequest, errors.New("paths must list one path per file")
+	}
+	stager, err := services.NewPackageStager()
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("Failed to save file temporarily")
+	}
+	defer stager.Close()
+	for i, fh := range files {
+		rel := fh.Filename
+		if len(paths) != 0 {
+			rel = paths[i]
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		err = stager.AddFile(rel, f)
+		f.Close()
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+	}
+	return writePackageZip(stager, "package")
+}
+
+// packageFromArchive normalizes the zip or tar.gz at src; fileName is the
+// name it was uploaded as.
+func packageFromArchive(src, fileName string) (string, int, error) {
+	stager, err := services.NewPackageStager()
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("Failed to save file temporarily")
+	}
+	defer stager.Close()
+	if err := stager.AddArchive(src); err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	name := filepath.Base(fileName)
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return writePackageZip(stager, name)
+}
+
+func writePackageZip(stager *services.PackageStager, name string) (string, int, error) {
+	zipPath := filepath.Join(utils.Config.Common.TempDir, utils.RandToken(10)+"_"+name+".zip")
+	if err := stager.WriteZip(zipPath); err != nil {
+		os.Remove(zipPath)
+		if err == services.ErrEmptyPackage {
+			return "", http.StatusBadRequest, err
+		}
+		return "", http.StatusInternalServerError, errors.New("Failed to assemble package")
+	}
+	return zipPath, http.StatusOK, nil
+}
+
 func (ctrl *AppsController) PromotePackage(c *gin.Context) {
 	user, _ := c.Get("user")
 	uid := user.(models.User).ID
@@ -298,22 +569,47 @@ func (ctrl *AppsController) PromotePackage(c *gin.Context) {
 	newPkg.DeploymentID = destDeployment.ID
 	newPkg.ReleaseMethod = "Promote"
 	newPkg.ReleasedBy = uid
-	if err := ctrl.DB.Create(&newPkg).Error; err != nil {
+	if err := ctrl.AppSvc.TouchPackage(&newPkg); err == services.ErrBlobCollected {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote package"})
 		return
 	}
-
-	destDeployment.LastDeploymentVersionID = newPkg.ID
-	destDeployment.LabelID++
-	if err := ctrl.DB.Save(destDeployment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deployment"})
+	previousID := destDeployment.LastDeploymentVersionID
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newPkg).Error; err != nil {
+			return err
+		}
+		if err := services.CopyReleaseNotes(tx, sourcePkg.ID, newPkg.ID); err != nil {
+			return err
+		}
+		destDeployment.LastDeploymentVersionID = newPkg.ID
+		destDeployment.LabelID++
+		return tx.Save(destDeployment).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote package"})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditPromote, AppID: collaborator.AppID, TargetType: "deployment", Target: destDeploymentName,
+		Before: ctrl.auditPackageByID(previousID), After: auditPackage(&newPkg),
+	})
+	ctrl.WebhookSvc.Emit(collaborator.AppID, services.WebhookPayload{
+		Event: models.WebhookEventPromote, App: appName, Deployment: destDeploymentName, Actor: user.(models.User).Email,
+		Package: services.NewWebhookPackage(&newPkg, user.(models.User).Email),
+	})
 
 	cfg := config.LoadConfig()
 	go ctrl.AppSvc.CreateDiffPackagesByLastNums(collaborator.AppID, &newPkg, cfg.Common.DiffNums)
 
-	c.JSON(http.StatusOK, gin.H{"package": newPkg})
+	// The diff goroutine holds newPkg, respond with a copy.
+	resp := newPkg
+	if resp.BlobURL, err = ctrl.AppSvc.PackageURL(c.Request.Context(), &newPkg); err != nil {
+		log.Printf("Failed to sign download URL of package %d: %v", newPkg.ID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"package": resp})
 }
 
 func (ctrl *AppsController) RollbackPackage(c *gin.Context) {
@@ -358,30 +654,130 @@ func (ctrl *AppsController) RollbackPackage(c *gin.Context) {
 	newPkg.ReleaseMethod = "Rollback"
 	newPkg.ReleasedBy = uid
 	newPkg.Label = "v" + strconv.Itoa(int(deployment.LabelID+1))
-	if err := ctrl.DB.Create(&newPkg).Error; err != nil {
+	if err := ctrl.AppSvc.TouchPackage(&newPkg); err == services.ErrBlobCollected {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rollback package"})
 		return
 	}
+	previousID := deployment.LastDeploymentVersionID
+	err = ctrl.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newPkg).Error; err != nil {
+			return err
+		}
+		if err := services.CopyReleaseNotes(tx, pkg.ID, newPkg.ID); err != nil {
+			return err
+		}
+		deployment.LastDeploymentVersionID = newPkg.ID
+		deployment.LabelID++
+		if err := tx.Save(deployment).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.DeploymentHistory{
+			DeploymentID: deployment.ID,
+			PackageID:    newPkg.ID,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rollback package"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditRollback, AppID: collaborator.AppID, TargetType: "deployment", Target: deploymentName,
+		Before: ctrl.auditPackageByID(previousID), After: auditPackage(&newPkg),
+	})
+	ctrl.WebhookSvc.Emit(collaborator.AppID, services.WebhookPayload{
+		Event: models.WebhookEventRollback, App: appName, Deployment: deploymentName, Actor: user.(models.User).Email,
+		Package: services.NewWebhookPackage(&newPkg, user.(models.User).Email),
+	})
+
+	cfg := config.LoadConfig()
+	go ctrl.AppSvc.CreateDiffPackagesByLastNums(collaborator.AppID, &newPkg, cfg.Common.DiffNums)
+
+	c.JSON(http.StatusOK, gin.H{"msg": "ok"})
+}
+
+func (ctrl *AppsController) GetReleaseNotes(c *gin.Context) {
+	pkg, ok := ctrl.findRelease(c)
+	if !ok {
+		return
+	}
 
-	deployment.LastDeploymentVersionID = newPkg.ID
-	deployment.LabelID++
-	if err := ctrl.DB.Save(deployment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deployment"})
+	notes, err := ctrl.AppSvc.ReleaseNotes(pkg.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch release notes"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"description": pkg.Description, "releaseNotes": notes})
+}
 
-	if err := ctrl.DB.Create(&models.DeploymentHistory{
-		DeploymentID: deployment.ID,
-		PackageID:    newPkg.ID,
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log history"})
+// SetReleaseNotes replaces the localized notes of a release, e.g. to add a
+// translation after the fact.
+func (ctrl *AppsController) SetReleaseNotes(c *gin.Context) {
+	pkg, ok := ctrl.findRelease(c)
+	if !ok {
 		return
 	}
 
-	cfg := config.LoadConfig()
-	go ctrl.AppSvc.CreateDiffPackagesByLastNums(collaborator.AppID, &newPkg, cfg.Common.DiffNums)
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	notes, err := services.ParseReleaseNotes(string(body))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ctrl.AppSvc.SaveReleaseNotes(pkg.ID, notes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save release notes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"releaseNotes": notes})
+}
 
-	c.JSON(http.StatusOK, gin.H{"msg": "ok"})
+func (ctrl *AppsController) findRelease(c *gin.Context) (*models.Package, bool) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	appName := strings.TrimSpace(c.Param("appName"))
+
+	collaborator, err := ctrl.AcctSvc.CollaboratorCan(uid, appName)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	deployment, err := ctrl.AppSvc.FindDeploymentByName(collaborator.AppID, strings.TrimSpace(c.Param("deploymentName")))
+	if err != nil || deployment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return nil, false
+	}
+	var pkg models.Package
+	if err := ctrl.DB.Where("deployment_id = ? AND label = ?", deployment.ID, c.Param("label")).First(&pkg).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return nil, false
+	}
+	return &pkg, true
+}
+
+func (ctrl *AppsController) auditPackageByID(id uint) gin.H {
+	var pkg models.Package
+	if id == 0 || ctrl.DB.First(&pkg, id).Error != nil {
+		return nil
+	}
+	return auditPackage(&pkg)
+}
+
+func auditPackage(pkg *models.Package) gin.H {
+	if pkg == nil {
+		return nil
+	}
+	return gin.H{
+		"label":       pkg.Label,
+		"packageHash": pkg.PackageHash,
+		"description": pkg.Description,
+		"isMandatory": pkg.IsMandatory == 1,
+	}
 }
 
 func (ctrl *AppsController) SetupRoutes(r *gin.Engine) {
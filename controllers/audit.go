@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"gorm.io/gorm"
+)
+
+type AuditController struct {
+	DB       *gorm.DB
+	AuditSvc *services.AuditService
+	AcctSvc  *services.AccountService
+}
+
+// AppAudit lists the audit trail of one app; only its owners may read it.
+func (ctrl *AuditController) AppAudit(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	appName := strings.TrimSpace(c.Param("appName"))
+
+	collaborator, err := ctrl.AcctSvc.OwnerCan(uid, appName)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.AppID = collaborator.AppID
+	ctrl.respond(c, filter)
+}
+
+// AccountAudit lists what the current user did.
+func (ctrl *AuditController) AccountAudit(c *gin.Context) {
+	user, _ := c.Get("user")
+
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.ActorID = user.(models.User).ID
+	filter.ActorEmail = ""
+	ctrl.respond(c, filter)
+}
+
+// AdminAudit lists the whole server's audit trail.
+func (ctrl *AuditController) AdminAudit(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	if appID := c.Query("appId"); appID != "" {
+		id, err := strconv.ParseUint(appID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appId"})
+			return
+		}
+		filter.AppID = uint(id)
+	}
+	ctrl.respond(c, filter)
+}
+
+func (ctrl *AuditController) respond(c *gin.Context, filter services.AuditFilter) {
+	page, pageSize := pagination(c)
+	entries, total, err := ctrl.AuditSvc.Query(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	result := make([]gin.H, len(entries))
+	for i, e := range entries {
+		result[i] = gin.H{
+			"id":          e.ID,
+			"action":      e.Action,
+			"actor":       e.ActorEmail,
+			"appId":       e.AppID,
+			"targetType":  e.TargetType,
+			"target":      e.Target,
+			"before":      rawJSON(e.Before),
+			"after":       rawJSON(e.After),
+			"ip":          e.IP,
+			"userAgent":   e.UserAgent,
+			"createdTime": e.CreatedAt.UnixMilli(),
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"auditLogs": result, "total": total, "page": page, "pageSize": pageSize})
+}
+
+// auditFilter reads the action, actor, from and to query parameters;
+// from and to are Unix milliseconds like every other time in the API.
+func auditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		Action:     c.Query("action"),
+		ActorEmail: c.Query("actor"),
+	}
+	for param, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(param); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return filter, false
+			}
+			*dst = time.UnixMilli(ms)
+		}
+	}
+	return filter, true
+}
+
+func rawJSON(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// recordAudit fills in the actor and request details of event from the
+// request context and records it.
+func recordAudit(c *gin.Context, svc *services.AuditService, event services.AuditEvent) {
+	if svc == nil {
+		return
+	}
+	if user, ok := c.Get("user"); ok && event.ActorID == 0 {
+		event.ActorID = user.(models.User).ID
+		event.ActorEmail = user.(models.User).Email
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	svc.Record(event)
+}
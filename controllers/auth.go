@@ -1,24 +1,37 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/middleware"
 	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
 	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
 
 type AuthController struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	SessionSvc   *services.SessionService
+	LoginGuard   *services.LoginGuard
+	TwoFactorSvc *services.TwoFactorService
+	LDAPSvc      *services.LDAPService
+	ResetSvc     *services.PasswordResetService
+	VerifySvc    *services.EmailVerificationService
+	InviteSvc    *services.InviteService
+	AuditSvc     *services.AuditService
 }
 
 func (ctrl *AuthController) Login(c *gin.Context) {
 	var input struct {
 		Account  string `form:"account" binding:"required"`
 		Password string `form:"password" binding:"required"`
+		OTP      string `form:"otp"`
 	}
 	if err := c.ShouldBind(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
@@ -26,35 +39,291 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := ctrl.DB.Where("email = ? OR username = ?", input.Account, input.Account).
-		Select("id, email, username, password, ack_code").
-		First(&user).Error; err != nil {
-		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Invalid email or password"})
+	lookupErr := ctrl.DB.Where("email = ? OR username = ?", input.Account, input.Account).
+		Select("id, email, username, password, ack_code, totp_secret, totp_enabled, totp_last_step, auth_source, oidc_linked, pending_verification, is_admin, is_disabled").
+		First(&user).Error
+
+	accountKey := services.AccountLoginKey(input.Account)
+	if lookupErr == nil {
+		accountKey = services.AccountLoginKey(user.Email)
+	}
+	ipKey := services.IPLoginKey(c.ClientIP())
+	if err := ctrl.LoginGuard.Check(accountKey, ipKey); err != nil {
+		ctrl.respondLoginError(c, err)
 		return
 	}
 
-	if !utils.VerifyPassword(input.Password, user.Password) {
-		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Invalid email or password"})
+	var existing *models.User
+	if lookupErr == nil {
+		existing = &user
+	}
+	authed, err := ctrl.verifyPassword(existing, input.Account, input.Password)
+	if err != nil {
+		ctrl.loginFailed(c, input.Account, accountKey, ipKey)
+		return
+	}
+	user = *authed
+	if user.IsDisabled == 1 {
+		c.JSON(http.StatusForbidden, gin.H{"status": "ERROR", "message": "Your account has been disabled, contact the server administrator"})
+		return
+	}
+	if user.PendingVerification == 1 {
+		c.JSON(http.StatusOK, gin.H{"status": "UNVERIFIED", "message": "Please confirm your email address first, check your inbox for the link"})
 		return
 	}
+	if user.TOTPEnabled == 1 {
+		if input.OTP == "" {
+			c.JSON(http.StatusOK, gin.H{"status": "OTP_REQUIRED", "message": "Enter the code from your authenticator app"})
+			return
+		}
+		if ok, err := ctrl.TwoFactorSvc.Verify(&user, input.OTP); err != nil || !ok {
+			ctrl.loginFailed(c, input.Account, accountKey, ipKey)
+			return
+		}
+	}
+	if err := ctrl.LoginGuard.Success(accountKey); err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", accountKey, err)
+	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"uid":       user.ID,
-		"hash":      utils.Md5(user.AckCode),
-		"expiredIn": 7200,
-	}).SignedString([]byte(utils.Config.JWT.TokenSecret))
+	tokens, err := ctrl.SessionSvc.Create(&user, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to generate token"})
 		return
 	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		ActorID: user.ID, ActorEmail: user.Email, Action: models.AuditLogin, TargetType: "user", Target: user.Email,
+	})
 
-	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": gin.H{"tokens": token}})
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": tokenResults(tokens)})
+}
+
+func (ctrl *AuthController) Refresh(c *gin.Context) {
+	var input struct {
+		RefreshToken string `form:"refreshToken" json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	tokens, err := ctrl.SessionSvc.Refresh(input.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": tokenResults(tokens)})
 }
 
 func (ctrl *AuthController) Logout(c *gin.Context) {
+	user, _ := c.Get("user")
+	// Access-key requests carry no session; there is nothing to revoke for them.
+	if sessionID, ok := c.Get("sessionID"); ok {
+		if err := ctrl.SessionSvc.Revoke(user.(models.User).ID, sessionID.(uint64)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to logout"})
+			return
+		}
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditLogout, TargetType: "user", Target: user.(models.User).Email})
+	c.JSON(http.StatusOK, "ok")
+}
+
+func (ctrl *AuthController) LogoutAll(c *gin.Context) {
+	user, _ := c.Get("user")
+	if err := ctrl.SessionSvc.RevokeAll(user.(models.User).ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to logout"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditLogoutAll, TargetType: "user", Target: user.(models.User).Email})
 	c.JSON(http.StatusOK, "ok")
 }
 
+func (ctrl *AuthController) VerifyEmail(c *gin.Context) {
+	user, err := ctrl.VerifySvc.Verify(c.Query("token"))
+	if err != nil {
+		c.HTML(http.StatusOK, "message.html", gin.H{"title": "CodePushServer", "heading": "Verification failed", "message": err.Error()})
+		return
+	}
+	c.HTML(http.StatusOK, "message.html", gin.H{
+		"title":   "CodePushServer",
+		"heading": "Email confirmed",
+		"message": user.Email + " is confirmed, you can now log in.",
+		"link":    "/auth/login?email=" + url.QueryEscape(user.Email),
+	})
+}
+
+func (ctrl *AuthController) ResendVerification(c *gin.Context) {
+	var input struct {
+		Email string `form:"email" json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	if err := ctrl.VerifySvc.Resend(input.Email); err != nil {
+		log.Printf("Failed to resend verification mail to %s: %v", input.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "If " + input.Email + " is awaiting confirmation, a new link has been sent"})
+}
+
+func (ctrl *AuthController) InvitePage(c *gin.Context) {
+	token := c.Query("token")
+	invite, err := ctrl.InviteSvc.Lookup(token)
+	if err != nil {
+		c.HTML(http.StatusOK, "message.html", gin.H{"title": "CodePushServer", "heading": "Invitation unavailable", "message": err.Error()})
+		return
+	}
+
+	var app models.App
+	ctrl.DB.Select("id, name").First(&app, invite.AppID)
+	var existing int64
+	ctrl.DB.Model(&models.User{}).Where("email = ?", invite.Email).Count(&existing)
+
+	c.HTML(http.StatusOK, "invite.html", gin.H{
+		"title":      "CodePushServer",
+		"token":      token,
+		"email":      invite.Email,
+		"appName":    app.Name,
+		"hasAccount": existing > 0,
+	})
+}
+
+func (ctrl *AuthController) AcceptInvite(c *gin.Context) {
+	var input struct {
+		Token    string `form:"token" json:"token" binding:"required"`
+		Password string `form:"password" json:"password"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	user, created, err := ctrl.InviteSvc.Accept(input.Token, input.Password)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+
+	message := "Invitation accepted, please log in"
+	if created {
+		message = "Account created and invitation accepted, please log in"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "message": message, "results": gin.H{"email": user.Email}})
+}
+
+func (ctrl *AuthController) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `form:"email" json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	if err := ctrl.ResetSvc.Request(input.Email); err != nil {
+		log.Printf("Failed to send password reset to %s: %v", input.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to send reset email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "If an account exists for " + input.Email + ", a reset link has been sent"})
+}
+
+func (ctrl *AuthController) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `form:"token" json:"token" binding:"required"`
+		Password string `form:"password" json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	user, err := ctrl.ResetSvc.Reset(input.Token, input.Password)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+	if err := ctrl.LoginGuard.Unlock(services.AccountLoginKey(user.Email)); err != nil {
+		log.Printf("Failed to clear login lockout for %s: %v", user.Email, err)
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		ActorID: user.ID, ActorEmail: user.Email, Action: models.AuditPasswordReset, TargetType: "user", Target: user.Email,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "Password changed, please log in"})
+}
+
+// verifyPassword checks the credentials against LDAP when it is configured,
+// then falls back to the local bcrypt password for local accounts so they
+// remain usable when the directory is down, does not know them, or knows
+// their email but the account was not created by a directory login.
+func (ctrl *AuthController) verifyPassword(user *models.User, account, password string) (*models.User, error) {
+	if ctrl.LDAPSvc.Enabled() && (user == nil || user.AuthSource != models.AuthSourceOIDC) {
+		identity, err := ctrl.LDAPSvc.Authenticate(account, password)
+		if err == nil {
+			var synced *models.User
+			synced, err = ctrl.LDAPSvc.SyncUser(identity, user)
+			if err == nil {
+				return synced, nil
+			}
+		}
+		if err != services.ErrInvalidCredentials {
+			log.Printf("LDAP authentication error for %s: %v", account, err)
+		}
+	}
+	if user != nil && isLocalAccount(user) && utils.VerifyPassword(password, user.Password) {
+		return user, nil
+	}
+	return nil, services.ErrInvalidCredentials
+}
+
+func isLocalAccount(user *models.User) bool {
+	return user.AuthSource == "" || user.AuthSource == models.AuthSourceLocal
+}
+
+// loginFailed records a failed attempt at account, as the client sent it, and
+// tells the client how many tries are left, or how long it is locked out for.
+func (ctrl *AuthController) loginFailed(c *gin.Context, account, accountKey, ipKey string) {
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		ActorEmail: account, Action: models.AuditLoginFailed, TargetType: "user", Target: account,
+	})
+	remaining, lockout, err := ctrl.LoginGuard.Failure(accountKey, ipKey)
+	if err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", accountKey, err)
+	}
+	if lockout > 0 {
+		ctrl.respondLoginError(c, &services.LoginLockedError{RetryAfter: lockout})
+		return
+	}
+	resp := gin.H{"status": "ERROR", "message": "Invalid email or password"}
+	if utils.Config.Common.TryLoginTimes > 0 {
+		resp["remainingAttempts"] = remaining
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (ctrl *AuthController) respondLoginError(c *gin.Context, err error) {
+	if locked, ok := err.(*services.LoginLockedError); ok {
+		retryAfter := int(locked.RetryAfter.Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"status": "ERROR", "message": locked.Error(), "lockedFor": retryAfter})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Login failed"})
+}
+
+func tokenResults(tokens *services.TokenPair) gin.H {
+	return gin.H{
+		"tokens":       tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+	}
+}
+
 func (ctrl *AuthController) Register(c *gin.Context) {
 	var input struct {
 		Email    string `form:"email" binding:"required,email"`
@@ -73,19 +342,29 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 	}
 
 	// Create new user
+	requireVerification := utils.Config.Common.RequireEmailVerification
 	user := models.User{
-		Email:     input.Email,
-		Username:  input.Email, // Use email as username for simplicity
-		Password:  utils.HashPassword(input.Password),
-		Identical: utils.RandToken(9),
-		AckCode:   utils.RandToken(5),
-		CreatedAt: time.Now(),
+		Email:               input.Email,
+		Username:            input.Email, // Use email as username for simplicity
+		Password:            utils.HashPassword(input.Password),
+		Identical:           utils.RandToken(9),
+		AckCode:             utils.RandToken(5),
+		PendingVerification: utils.BoolToUint8(requireVerification),
+		CreatedAt:           time.Now(),
 	}
 	if err := ctrl.DB.Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to register user"})
 		return
 	}
 
+	if requireVerification {
+		if err := ctrl.VerifySvc.Send(&user); err != nil {
+			log.Printf("Failed to send verification mail to %s: %v", user.Email, err)
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "Registration successful, please confirm your email address using the link we sent"})
+		return
+	}
+
 	// Immediately return success and redirect to login
 	c.JSON(http.StatusOK, gin.H{"status": "OK", "message": "Registration successful, please log in"})
 }
@@ -114,7 +393,9 @@ func (ctrl *AuthController) SetupRoutes(r *gin.Engine) {
 			})
 		})
 		auth.POST("/login", ctrl.Login)
-		auth.POST("/logout", ctrl.Logout)
+		auth.POST("/refresh", ctrl.Refresh)
+		auth.POST("/logout", middleware.AuthMiddleware(ctrl.DB), ctrl.Logout)
+		auth.POST("/logout/all", middleware.AuthMiddleware(ctrl.DB), ctrl.LogoutAll)
 		auth.POST("/register", ctrl.Register)
 	}
 }
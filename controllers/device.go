@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// DeviceController serves the device authorization flow used by the CLI.
+// The unauthenticated endpoints follow RFC 8628 field names.
+type DeviceController struct {
+	DB        *gorm.DB
+	DeviceSvc *services.DeviceService
+	AuditSvc  *services.AuditService
+}
+
+func (ctrl *DeviceController) Code(c *gin.Context) {
+	var input struct {
+		ClientName string `form:"client_name" json:"client_name"`
+	}
+	_ = c.ShouldBind(&input)
+	if input.ClientName == "" {
+		input.ClientName = "CLI"
+	}
+
+	auth, err := ctrl.DeviceSvc.Start(input.ClientName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	verificationURI := utils.Config.Common.PublicURL + "/tokens"
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               auth.DeviceCode,
+		"user_code":                 auth.UserCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + auth.UserCode,
+		"expires_in":                auth.ExpiresIn,
+		"interval":                  auth.Interval,
+	})
+}
+
+func (ctrl *DeviceController) Token(c *gin.Context) {
+	var input struct {
+		DeviceCode string `form:"device_code" json:"device_code" binding:"required"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	token, err := ctrl.DeviceSvc.Poll(input.DeviceCode)
+	switch err {
+	case nil:
+	case services.ErrAuthorizationPending, services.ErrSlowDown, services.ErrAccessDenied, services.ErrExpiredToken:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	var owner models.User
+	ctrl.DB.Select("id, email").First(&owner, token.UID)
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		ActorID: owner.ID, ActorEmail: owner.Email,
+		Action: models.AuditAccessKeyCreate, TargetType: "access_key", Target: token.Name,
+		After: gin.H{"createdBy": token.CreatedBy, "description": token.Description, "expires": token.ExpiresAt.Time.UnixMilli()},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"accessKey": gin.H{
+		"name":    token.Tokens,
+		"expires": token.ExpiresAt.Time.UnixMilli(),
+	}})
+}
+
+func (ctrl *DeviceController) Lookup(c *gin.Context) {
+	code, err := ctrl.DeviceSvc.Lookup(c.Query("user_code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"device": gin.H{"userCode": code.UserCode, "clientName": code.ClientName}})
+}
+
+func (ctrl *DeviceController) Decide(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	var input struct {
+		UserCode string `json:"userCode" binding:"required"`
+		Approve  bool   `json:"approve"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := ctrl.DeviceSvc.Decide(uid, input.UserCode, input.Approve); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
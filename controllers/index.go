@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"io"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/venkatvghub/code-push-server-go/middleware"
 	"github.com/venkatvghub/code-push-server-go/services"
+	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
 
@@ -32,8 +36,9 @@ func (ctrl *IndexController) UpdateCheck(c *gin.Context) {
 	label := c.Query("label")
 	packageHash := c.Query("packageHash")
 	clientUniqueID := c.Query("clientUniqueId")
+	locale := c.Query("locale")
 
-	updateInfo, err := ctrl.ClientSvc.UpdateCheck(deploymentKey, appVersion, label, packageHash, clientUniqueID)
+	updateInfo, err := ctrl.ClientSvc.UpdateCheck(c.Request.Context(), deploymentKey, appVersion, label, packageHash, clientUniqueID, locale)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -42,6 +47,32 @@ func (ctrl *IndexController) UpdateCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"updateInfo": updateInfo})
 }
 
+// Download serves packages kept in local storage. While download URLs
+// expire, only URLs handed out by update checks are served.
+func (ctrl *IndexController) Download(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if utils.SignDownloads() && !utils.VerifyLocalDownload(key, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download URL"})
+		return
+	}
+	info, err := ctrl.ClientSvc.Storage.Stat(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	r, err := ctrl.ClientSvc.Storage.Get(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	defer r.Close()
+	if f, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, f)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, "application/zip", r, nil)
+}
+
 func (ctrl *IndexController) ReportStatusDownload(c *gin.Context) {
 	var input struct {
 		ClientUniqueID string `json:"clientUniqueId" binding:"required"`
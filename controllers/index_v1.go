@@ -19,8 +19,9 @@ func (ctrl *IndexV1Controller) UpdateCheck(c *gin.Context) {
 	label := c.Query("label")
 	packageHash := c.Query("package_hash")
 	clientUniqueID := c.Query("client_unique_id")
+	locale := c.Query("locale")
 
-	updateInfo, err := ctrl.ClientSvc.UpdateCheck(deploymentKey, appVersion, label, packageHash, clientUniqueID)
+	updateInfo, err := ctrl.ClientSvc.UpdateCheck(c.Request.Context(), deploymentKey, appVersion, label, packageHash, clientUniqueID, locale)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -30,6 +31,7 @@ func (ctrl *IndexV1Controller) UpdateCheck(c *gin.Context) {
 		"update_info": gin.H{
 			"download_url":              updateInfo["downloadUrl"],
 			"description":               updateInfo["description"],
+			"release_notes":             updateInfo["releaseNotes"],
 			"is_available":              updateInfo["isAvailable"],
 			"is_disabled":               updateInfo["isDisabled"],
 			"target_binary_range":       updateInfo["appVersion"],
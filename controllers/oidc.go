@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcCookie    = "oidc_login"
+	oidcOTPCookie = "oidc_otp"
+)
+
+type OIDCController struct {
+	DB           *gorm.DB
+	OIDCSvc      *services.OIDCService
+	SessionSvc   *services.SessionService
+	TwoFactorSvc *services.TwoFactorService
+	LoginGuard   *services.LoginGuard
+	AuditSvc     *services.AuditService
+}
+
+// Login starts the authorization code flow. The state, nonce and PKCE
+// verifier live in a short-lived HttpOnly cookie until the callback.
+func (ctrl *OIDCController) Login(c *gin.Context) {
+	state := utils.RandSecret(16)
+	nonce := utils.RandSecret(16)
+	verifier := utils.RandSecret(32)
+
+	authURL, err := ctrl.OIDCSvc.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		c.HTML(http.StatusServiceUnavailable, "oidc_callback.html", gin.H{"title": "CodePushServer", "error": err.Error()})
+		return
+	}
+
+	flow := url.Values{}
+	flow.Set("state", state)
+	flow.Set("nonce", nonce)
+	flow.Set("verifier", verifier)
+	flow.Set("hostname", c.Query("hostname"))
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, flow.Encode(), 600, "/auth/oidc", "", strings.HasPrefix(utils.Config.Common.PublicURL, "https://"), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (ctrl *OIDCController) Callback(c *gin.Context) {
+	raw, err := c.Cookie(oidcCookie)
+	c.SetCookie(oidcCookie, "", -1, "/auth/oidc", "", false, true)
+	if err != nil {
+		ctrl.callbackError(c, "Login session expired, please try again")
+		return
+	}
+	flow, err := url.ParseQuery(raw)
+	if err != nil || flow.Get("state") == "" || flow.Get("state") != c.Query("state") {
+		ctrl.callbackError(c, "Invalid login state, please try again")
+		return
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		ctrl.callbackError(c, "Identity provider returned "+errCode+": "+c.Query("error_description"))
+		return
+	}
+
+	user, err := ctrl.OIDCSvc.Authenticate(c.Query("code"), flow.Get("verifier"), flow.Get("nonce"))
+	if err != nil {
+		ctrl.callbackError(c, err.Error())
+		return
+	}
+
+	// The provider does not know about the account's TOTP, so it is asked
+	// for here, as on a password login.
+	if user.TOTPEnabled == 1 {
+		pending := url.Values{}
+		pending.Set("user", ctrl.OIDCSvc.PendingOTP(user))
+		pending.Set("hostname", flow.Get("hostname"))
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcOTPCookie, pending.Encode(), int(services.OIDCOTPTTL.Seconds()), "/auth/oidc", "", strings.HasPrefix(utils.Config.Common.PublicURL, "https://"), true)
+		c.HTML(http.StatusOK, "oidc_callback.html", gin.H{"title": "CodePushServer", "otp": true})
+		return
+	}
+	ctrl.signIn(c, user, flow.Get("hostname"))
+}
+
+// OTP finishes a provider login of an account with two-factor authentication
+// enabled, once the code from its authenticator app or a recovery code is
+// entered.
+func (ctrl *OIDCController) OTP(c *gin.Context) {
+	raw, err := c.Cookie(oidcOTPCookie)
+	if err != nil {
+		ctrl.callbackError(c, "Login session expired, please try again")
+		return
+	}
+	pending, err := url.ParseQuery(raw)
+	if err != nil {
+		ctrl.callbackError(c, "Login session expired, please try again")
+		return
+	}
+	user, err := ctrl.OIDCSvc.PendingOTPUser(pending.Get("user"))
+	if err != nil {
+		c.SetCookie(oidcOTPCookie, "", -1, "/auth/oidc", "", false, true)
+		ctrl.callbackError(c, "Login session expired, please try again")
+		return
+	}
+
+	accountKey := services.AccountLoginKey(user.Email)
+	ipKey := services.IPLoginKey(c.ClientIP())
+	if err := ctrl.LoginGuard.Check(accountKey, ipKey); err != nil {
+		ctrl.callbackError(c, err.Error())
+		return
+	}
+	if ok, err := ctrl.TwoFactorSvc.Verify(user, c.PostForm("otp")); err != nil || !ok {
+		recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+			ActorEmail: user.Email, Action: models.AuditLoginFailed, TargetType: "user", Target: user.Email,
+			After: gin.H{"method": "oidc"},
+		})
+		if _, lockout, err := ctrl.LoginGuard.Failure(accountKey, ipKey); err != nil {
+			log.Printf("Failed to record login attempt for %s: %v", accountKey, err)
+		} else if lockout > 0 {
+			c.SetCookie(oidcOTPCookie, "", -1, "/auth/oidc", "", false, true)
+			ctrl.callbackError(c, (&services.LoginLockedError{RetryAfter: lockout}).Error())
+			return
+		}
+		c.HTML(http.StatusUnauthorized, "oidc_callback.html", gin.H{"title": "CodePushServer", "otp": true, "otpError": "Invalid code, please try again"})
+		return
+	}
+	if err := ctrl.LoginGuard.Success(accountKey); err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", accountKey, err)
+	}
+	c.SetCookie(oidcOTPCookie, "", -1, "/auth/oidc", "", false, true)
+	ctrl.signIn(c, user, pending.Get("hostname"))
+}
+
+// signIn creates the session of a provider login and hands its tokens to the
+// browser.
+func (ctrl *OIDCController) signIn(c *gin.Context, user *models.User, hostname string) {
+	tokens, err := ctrl.SessionSvc.Create(user, c.ClientIP(), c.Request.UserAgent())
+	if err == services.ErrAccountDisabled {
+		ctrl.callbackError(c, "Your account has been disabled, contact the server administrator")
+		return
+	} else if err != nil {
+		ctrl.callbackError(c, "Failed to generate token")
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		ActorID: user.ID, ActorEmail: user.Email, Action: models.AuditLogin, TargetType: "user", Target: user.Email,
+		After: gin.H{"method": "oidc"},
+	})
+
+	redirect := "/"
+	if hostname != "" {
+		redirect = "/tokens?hostname=" + url.QueryEscape(hostname)
+	}
+	c.HTML(http.StatusOK, "oidc_callback.html", gin.H{
+		"title":        "CodePushServer",
+		"tokens":       tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"redirect":     redirect,
+	})
+}
+
+func (ctrl *OIDCController) callbackError(c *gin.Context, message string) {
+	c.HTML(http.StatusUnauthorized, "oidc_callback.html", gin.H{"title": "CodePushServer", "error": message})
+}
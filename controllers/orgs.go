@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"gorm.io/gorm"
+)
+
+type OrgsController struct {
+	DB       *gorm.DB
+	OrgSvc   *services.OrgService
+	AcctSvc  *services.AccountService
+	AuditSvc *services.AuditService
+}
+
+func (ctrl *OrgsController) AddOrg(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	org, err := ctrl.OrgSvc.CreateOrg(uid, strings.TrimSpace(input.Name))
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditOrgCreate, TargetType: "org", Target: org.Name})
+
+	c.JSON(http.StatusOK, gin.H{"organization": gin.H{"name": org.Name, "role": models.OrgRoleOwner}})
+}
+
+func (ctrl *OrgsController) ListOrgs(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	orgs, err := ctrl.OrgSvc.ListOrgs(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	result := make([]gin.H, len(orgs))
+	for i, org := range orgs {
+		result[i] = gin.H{"name": org.Name, "role": org.Role}
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": result})
+}
+
+func (ctrl *OrgsController) GetOrg(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	org, role, err := ctrl.OrgSvc.MemberCan(uid, c.Param("orgName"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	members, err := ctrl.OrgSvc.Members(org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+	membersJSON := make(map[string]gin.H)
+	for _, member := range members {
+		var userModel models.User
+		if err := ctrl.DB.Where("id = ?", member.UID).First(&userModel).Error; err == nil {
+			membersJSON[userModel.Email] = gin.H{
+				"role":             member.Role,
+				"isCurrentAccount": member.UID == uid,
+			}
+		}
+	}
+
+	apps, err := ctrl.OrgSvc.Apps(org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch apps"})
+		return
+	}
+	appNames := make([]string, len(apps))
+	for i, app := range apps {
+		appNames[i] = services.AppFullName(org.Name, app.Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": gin.H{
+		"name":    org.Name,
+		"role":    role,
+		"members": membersJSON,
+		"apps":    appNames,
+	}})
+}
+
+func (ctrl *OrgsController) DeleteOrg(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	org, _, err := ctrl.OrgSvc.MemberCan(uid, c.Param("orgName"), models.OrgRoleOwner)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctrl.OrgSvc.DeleteOrg(org.ID); err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditOrgDelete, TargetType: "org", Target: org.Name})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// SetMember adds a member or changes their role. Admins manage members and
+// admins; only owners can appoint or demote owners.
+func (ctrl *OrgsController) SetMember(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	email := strings.TrimSpace(c.Param("email"))
+
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.OrgRoleMember
+	}
+
+	org, callerRole, err := ctrl.OrgSvc.MemberCan(uid, c.Param("orgName"), models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := ctrl.AcctSvc.FindUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if callerRole != models.OrgRoleOwner &&
+		(input.Role == models.OrgRoleOwner || ctrl.OrgSvc.MemberRole(org.ID, target.ID) == models.OrgRoleOwner) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "permission denied, only owners can manage owners"})
+		return
+	}
+
+	previousRole := ctrl.OrgSvc.MemberRole(org.ID, target.ID)
+	if err := ctrl.OrgSvc.SetMember(org.ID, target.ID, input.Role); err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditOrgMemberSet, TargetType: "org_member", Target: org.Name + ":" + target.Email,
+		Before: gin.H{"role": previousRole}, After: gin.H{"role": input.Role},
+	})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// RemoveMember is open to owners and admins, and to any member leaving.
+func (ctrl *OrgsController) RemoveMember(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+	email := strings.TrimSpace(c.Param("email"))
+
+	org, callerRole, err := ctrl.OrgSvc.MemberCan(uid, c.Param("orgName"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := ctrl.AcctSvc.FindUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if target.ID != uid {
+		targetRole := ctrl.OrgSvc.MemberRole(org.ID, target.ID)
+		if callerRole == models.OrgRoleMember || (callerRole == models.OrgRoleAdmin && targetRole == models.OrgRoleOwner) {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": "permission denied"})
+			return
+		}
+	}
+
+	previousRole := ctrl.OrgSvc.MemberRole(org.ID, target.ID)
+	if err := ctrl.OrgSvc.RemoveMember(org.ID, target.ID); err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{
+		Action: models.AuditOrgMemberRemove, TargetType: "org_member", Target: org.Name + ":" + target.Email,
+		Before: gin.H{"role": previousRole},
+	})
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"gorm.io/gorm"
+)
+
+// UploadsController takes packages in chunks for clients on unreliable
+// connections and releases them like AppsController.ReleasePackage.
+type UploadsController struct {
+	DB        *gorm.DB
+	Apps      *AppsController
+	UploadSvc *services.UploadService
+}
+
+func (ctrl *UploadsController) CreateUpload(c *gin.Context) {
+	user, _ := c.Get("user")
+	collaborator, deployment, ok := ctrl.deployment(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		FileName string `json:"fileName" binding:"required"`
+		Size     int64  `json:"size" binding:"required"`
+		SHA256   string `json:"sha256"`
+		Direct   bool   `json:"direct"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if input.Direct {
+		session, uploadURL, headers, err := ctrl.UploadSvc.CreateDirect(c.Request.Context(), user.(models.User).ID, collaborator.AppID, deployment.ID, input.FileName, input.Size, input.SHA256)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uploadHeaders := gin.H{}
+		for name := range headers {
+			uploadHeaders[name] = headers.Get(name)
+		}
+		result := uploadJSON(session)
+		result["uploadUrl"] = uploadURL
+		result["uploadHeaders"] = uploadHeaders
+		c.JSON(http.StatusOK, gin.H{"upload": result})
+		return
+	}
+
+	session, err := ctrl.UploadSvc.Create(user.(models.User).ID, collaborator.AppID, deployment.ID, input.FileName, input.Size, input.SHA256)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upload": uploadJSON(session)})
+}
+
+// GetUpload tells a client where to resume.
+func (ctrl *UploadsController) GetUpload(c *gin.Context) {
+	session, _, _, ok := ctrl.session(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upload": uploadJSON(session)})
+}
+
+// PutChunk appends the request body at the offset query parameter. The
+// optional X-Chunk-SHA256 header holds the hex SHA-256 of the chunk.
+func (ctrl *UploadsController) PutChunk(c *gin.Context) {
+	session, _, _, ok := ctrl.session(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	received, err := ctrl.UploadSvc.WriteChunk(session, offset, c.Request.Body, c.GetHeader("X-Chunk-SHA256"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"upload": uploadJSON(session)})
+	case services.ErrUploadOffset:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "offset": received})
+	case services.ErrUploadChecksum, services.ErrUploadChunkTooLarge, services.ErrDirectUpload:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "offset": received})
+	case services.ErrUploadNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk", "offset": received})
+	}
+}
+
+// FinalizeUpload releases the uploaded package. It takes the same
+// description, isMandatory and releaseNotes form fields as a release.
+func (ctrl *UploadsController) FinalizeUpload(c *gin.Context) {
+	session, collaborator, deployment, ok := ctrl.session(c)
+	if !ok {
+		return
+	}
+	releaseNotes, err := services.ParseReleaseNotes(c.PostForm("releaseNotes"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var blob *models.Blob
+	if session.StagingKey != "" {
+		blob, err = ctrl.UploadSvc.CommitDirect(c.Request.Context(), session)
+	} else {
+		err = ctrl.UploadSvc.Complete(session)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == services.ErrUploadNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "offset": session.Received})
+		return
+	}
+	// The session stays until the release succeeded, so a failed one can
+	// be finalized again.
+	appName := strings.TrimSpace(c.Param("appName"))
+	if blob != nil {
+		if !ctrl.Apps.releaseStored(c, collaborator.AppID, appName, deployment, blob, releaseNotes) {
+			ctrl.UploadSvc.Reopen(session)
+			return
+		}
+		ctrl.UploadSvc.Delete(session)
+		return
+	}
+	zipPath, status, err := packageFromArchive(services.UploadPath(session), session.FileName)
+	if err != nil {
+		ctrl.UploadSvc.Reopen(session)
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if !ctrl.Apps.release(c, collaborator.AppID, appName, deployment, zipPath, releaseNotes) {
+		ctrl.UploadSvc.Reopen(session)
+		return
+	}
+	ctrl.UploadSvc.Delete(session)
+}
+
+func (ctrl *UploadsController) DeleteUpload(c *gin.Context) {
+	session, _, _, ok := ctrl.session(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.UploadSvc.Delete(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete upload"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (ctrl *UploadsController) deployment(c *gin.Context) (*models.Collaborator, *models.Deployment, bool) {
+	user, _ := c.Get("user")
+	collaborator, err := ctrl.Apps.AcctSvc.CollaboratorCan(user.(models.User).ID, strings.TrimSpace(c.Param("appName")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	deployment, err := ctrl.Apps.AppSvc.FindDeploymentByName(collaborator.AppID, strings.TrimSpace(c.Param("deploymentName")))
+	if err != nil || deployment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return nil, nil, false
+	}
+	return collaborator, deployment, true
+}
+
+func (ctrl *UploadsController) session(c *gin.Context) (*models.UploadSession, *models.Collaborator, *models.Deployment, bool) {
+	user, _ := c.Get("user")
+	collaborator, deployment, ok := ctrl.deployment(c)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	session, err := ctrl.UploadSvc.Find(user.(models.User).ID, deployment.ID, c.Param("uploadID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, nil, nil, false
+	}
+	if _, err := os.Stat(services.UploadPath(session)); session.StagingKey == "" && err != nil {
+		// The temp dir was wiped, the upload has to start over.
+		ctrl.UploadSvc.Delete(session)
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrUploadNotFound.Error()})
+		return nil, nil, nil, false
+	}
+	return session, collaborator, deployment, true
+}
+
+func uploadJSON(session *models.UploadSession) gin.H {
+	return gin.H{
+		"id":          session.ID,
+		"fileName":    session.FileName,
+		"size":        session.Size,
+		"offset":      session.Received,
+		"expiresTime": session.ExpiresAt.UnixMilli(),
+	}
+}
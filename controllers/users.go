@@ -2,17 +2,22 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/venkatvghub/code-push-server-go/middleware"
 	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
 	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
 
 type UsersController struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	SessionSvc   *services.SessionService
+	TwoFactorSvc *services.TwoFactorService
+	AuditSvc     *services.AuditService
 }
 
 func (ctrl *UsersController) ChangePassword(c *gin.Context) {
@@ -34,6 +39,11 @@ func (ctrl *UsersController) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	if !isLocalAccount(&userModel) {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Password is managed by your " + userModel.AuthSource + " identity provider"})
+		return
+	}
+
 	if !utils.VerifyPassword(input.OldPassword, userModel.Password) {
 		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Incorrect old password"})
 		return
@@ -45,6 +55,169 @@ func (ctrl *UsersController) ChangePassword(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to update password"})
 		return
 	}
+	if err := ctrl.SessionSvc.RevokeAll(uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to revoke sessions"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditPasswordChange, TargetType: "user", Target: userModel.Email})
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (ctrl *UsersController) EnrollTwoFactor(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+
+	secret, uri, err := ctrl.TwoFactorSvc.Enroll(&userModel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": gin.H{"secret": secret, "otpauthUri": uri}})
+}
+
+func (ctrl *UsersController) ConfirmTwoFactor(c *gin.Context) {
+	user, _ := c.Get("user")
+	uid := user.(models.User).ID
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	codes, err := ctrl.TwoFactorSvc.Confirm(uid, input.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditTwoFactorEnable, TargetType: "user", Target: user.(models.User).Email})
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": gin.H{"recoveryCodes": codes}})
+}
+
+func (ctrl *UsersController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	if userModel.TOTPEnabled != 1 {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Two-factor authentication is not enabled"})
+		return
+	}
+	if ok, err := ctrl.TwoFactorSvc.Verify(&userModel, input.Code); err != nil || !ok {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "Invalid two-factor code"})
+		return
+	}
+
+	codes, err := ctrl.TwoFactorSvc.RegenerateRecoveryCodes(userModel.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "results": gin.H{"recoveryCodes": codes}})
+}
+
+func (ctrl *UsersController) DisableTwoFactor(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	if err := ctrl.reauthenticate(&userModel, input.Password, input.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+
+	if err := ctrl.TwoFactorSvc.Disable(userModel.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to disable two-factor authentication"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditTwoFactorDisable, TargetType: "user", Target: userModel.Email})
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// LinkOIDC lets single sign-on log in to the current local or directory
+// account. Like disabling 2FA, it needs the account to re-authenticate.
+func (ctrl *UsersController) LinkOIDC(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "ERROR", "message": "Invalid input"})
+		return
+	}
+
+	if userModel.AuthSource == models.AuthSourceOIDC {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": "This account already logs in with single sign-on"})
+		return
+	}
+	if err := ctrl.reauthenticate(&userModel, input.Password, input.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ERROR", "message": err.Error()})
+		return
+	}
+
+	if err := ctrl.DB.Model(&models.User{}).Where("id = ?", userModel.ID).Update("oidc_linked", 1).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to link single sign-on"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditOIDCLink, TargetType: "user", Target: userModel.Email})
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// reauthenticate checks the password of a local account, and a 2FA code if
+// it is enabled. Accounts whose password is kept by LDAP or the single
+// sign-on provider re-authenticate with a 2FA or recovery code alone, so they
+// need 2FA enabled for changes that ask for it.
+func (ctrl *UsersController) reauthenticate(user *models.User, password, code string) error {
+	if isLocalAccount(user) {
+		if !utils.VerifyPassword(password, user.Password) {
+			return errors.New("Incorrect password")
+		}
+	} else if user.TOTPEnabled != 1 {
+		return errors.New("Your password is managed by your " + user.AuthSource + " identity provider, enable two-factor authentication to confirm this change with a code")
+	}
+	if user.TOTPEnabled == 1 {
+		if ok, err := ctrl.TwoFactorSvc.Verify(user, code); err != nil || !ok {
+			return errors.New("Invalid two-factor code")
+		}
+	}
+	return nil
+}
+
+func (ctrl *UsersController) UnlinkOIDC(c *gin.Context) {
+	user, _ := c.Get("user")
+	userModel := user.(models.User)
+
+	if err := ctrl.DB.Model(&models.User{}).Where("id = ?", userModel.ID).Update("oidc_linked", 0).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "ERROR", "message": "Failed to unlink single sign-on"})
+		return
+	}
+	recordAudit(c, ctrl.AuditSvc, services.AuditEvent{Action: models.AuditOIDCUnlink, TargetType: "user", Target: userModel.Email})
 
 	c.JSON(http.StatusOK, gin.H{"status": "OK"})
 }
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"gorm.io/gorm"
+)
+
+// WebhooksController lets app owners manage the webhooks of their apps.
+type WebhooksController struct {
+	DB         *gorm.DB
+	AppSvc     *services.AppService
+	AcctSvc    *services.AccountService
+	WebhookSvc *services.WebhookService
+}
+
+func (ctrl *WebhooksController) ListWebhooks(c *gin.Context) {
+	collaborator, ok := ctrl.ownerCan(c)
+	if !ok {
+		return
+	}
+
+	hooks, err := ctrl.WebhookSvc.List(collaborator.AppID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+	deployments := ctrl.deploymentNames(collaborator.AppID)
+	result := make([]gin.H, len(hooks))
+	for i := range hooks {
+		result[i] = webhookJSON(&hooks[i], deployments)
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": result})
+}
+
+func (ctrl *WebhooksController) AddWebhook(c *gin.Context) {
+	user, _ := c.Get("user")
+	collaborator, ok := ctrl.ownerCan(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL        string   `json:"url" binding:"required"`
+		Format     string   `json:"format"`
+		Deployment string   `json:"deployment"`
+		Events     []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	deploymentID, ok := ctrl.deploymentID(c, collaborator.AppID, input.Deployment)
+	if !ok {
+		return
+	}
+
+	hook, err := ctrl.WebhookSvc.Create(collaborator.AppID, deploymentID, input.URL, input.Format, input.Events, user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The secret is only shown once, receivers need it to verify signatures.
+	result := webhookJSON(hook, ctrl.deploymentNames(collaborator.AppID))
+	result["secret"] = hook.Secret
+	c.JSON(http.StatusOK, gin.H{"webhook": result})
+}
+
+func (ctrl *WebhooksController) UpdateWebhook(c *gin.Context) {
+	hook, ok := ctrl.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL        *string  `json:"url"`
+		Format     *string  `json:"format"`
+		Deployment *string  `json:"deployment"`
+		Events     []string `json:"events"`
+		Active     *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	changes := services.WebhookChanges{URL: input.URL, Format: input.Format, Events: input.Events, Active: input.Active}
+	if input.Deployment != nil {
+		deploymentID, ok := ctrl.deploymentID(c, hook.AppID, *input.Deployment)
+		if !ok {
+			return
+		}
+		changes.DeploymentID = &deploymentID
+	}
+
+	if err := ctrl.WebhookSvc.Update(hook, changes); err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	hook, _ = ctrl.WebhookSvc.Find(hook.AppID, hook.ID)
+	c.JSON(http.StatusOK, gin.H{"webhook": webhookJSON(hook, ctrl.deploymentNames(hook.AppID))})
+}
+
+func (ctrl *WebhooksController) DeleteWebhook(c *gin.Context) {
+	hook, ok := ctrl.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.WebhookSvc.Delete(hook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (ctrl *WebhooksController) ListDeliveries(c *gin.Context) {
+	hook, ok := ctrl.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := pagination(c)
+	deliveries, total, err := ctrl.WebhookSvc.Deliveries(hook.ID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+	result := make([]gin.H, len(deliveries))
+	for i := range deliveries {
+		result[i] = deliveryJSON(&deliveries[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": result, "total": total, "page": page, "pageSize": pageSize})
+}
+
+// TestWebhook sends a ping event right away and reports the outcome.
+func (ctrl *WebhooksController) TestWebhook(c *gin.Context) {
+	user, _ := c.Get("user")
+	hook, ok := ctrl.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := ctrl.WebhookSvc.Test(hook, strings.TrimSpace(c.Param("appName")), user.(models.User).Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": deliveryJSON(delivery)})
+}
+
+func (ctrl *WebhooksController) ownerCan(c *gin.Context) (*models.Collaborator, bool) {
+	user, _ := c.Get("user")
+	collaborator, err := ctrl.AcctSvc.OwnerCan(user.(models.User).ID, strings.TrimSpace(c.Param("appName")))
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return collaborator, true
+}
+
+func (ctrl *WebhooksController) findWebhook(c *gin.Context) (*models.Webhook, bool) {
+	collaborator, ok := ctrl.ownerCan(c)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("webhookID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook id"})
+		return nil, false
+	}
+	hook, err := ctrl.WebhookSvc.Find(collaborator.AppID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return hook, true
+}
+
+// deploymentID resolves the deployment a webhook is scoped to; an empty
+// name means the whole app.
+func (ctrl *WebhooksController) deploymentID(c *gin.Context, appID uint, name string) (uint, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, true
+	}
+	deployment, err := ctrl.AppSvc.FindDeploymentByName(appID, name)
+	if err != nil || deployment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return 0, false
+	}
+	return deployment.ID, true
+}
+
+func (ctrl *WebhooksController) deploymentNames(appID uint) map[uint]string {
+	var deployments []models.Deployment
+	ctrl.DB.Where("app_id = ?", appID).Find(&deployments)
+	names := make(map[uint]string, len(deployments))
+	for _, d := range deployments {
+		names[d.ID] = d.Name
+	}
+	return names
+}
+
+func webhookJSON(hook *models.Webhook, deployments map[uint]string) gin.H {
+	events := []string{}
+	if hook.Events != "" {
+		events = strings.Split(hook.Events, ",")
+	}
+	var deployment interface{}
+	if hook.DeploymentID != 0 {
+		deployment = deployments[hook.DeploymentID]
+	}
+	return gin.H{
+		"id":          hook.ID,
+		"url":         hook.URL,
+		"format":      hook.Format,
+		"deployment":  deployment,
+		"events":      events,
+		"active":      hook.IsActive == 1,
+		"createdTime": hook.CreatedAt.UnixMilli(),
+	}
+}
+
+func deliveryJSON(d *models.WebhookDelivery) gin.H {
+	result := gin.H{
+		"id":              d.ID,
+		"event":           d.Event,
+		"payload":         rawJSON(d.Payload),
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"lastStatusCode":  d.LastStatusCode,
+		"lastError":       d.LastError,
+		"createdTime":     d.CreatedAt.UnixMilli(),
+		"nextAttemptTime": nil,
+		"deliveredTime":   nil,
+	}
+	if d.Status == models.WebhookDeliveryPending {
+		result["nextAttemptTime"] = d.NextAttemptAt.UnixMilli()
+	}
+	if d.DeliveredAt != nil {
+		result["deliveredTime"] = d.DeliveredAt.UnixMilli()
+	}
+	return result
+}
@@ -43,11 +43,26 @@ response=$(curl -s -X POST "$BASE_URL/auth/login" \
     -d "account=aaa@bbb.com&password=123456")
 check_response "$response" '"status":"OK"' "Login should succeed"
 JWT_TOKEN=$(echo "$response" | grep -o '"tokens":"[^"]*"' | cut -d'"' -f4)
+REFRESH_TOKEN=$(echo "$response" | grep -o '"refreshToken":"[^"]*"' | cut -d'"' -f4)
 
-# Logout (POST /auth/logout)
+# Refresh (POST /auth/refresh)
+echo "Testing POST /auth/refresh"
+response=$(curl -s -X POST "$BASE_URL/auth/refresh" \
+    -H "Content-Type: application/x-www-form-urlencoded" \
+    -d "refreshToken=$REFRESH_TOKEN")
+check_response "$response" '"status":"OK"' "Refresh should succeed"
+JWT_TOKEN=$(echo "$response" | grep -o '"tokens":"[^"]*"' | cut -d'"' -f4)
+
+# Logout (POST /auth/logout) using a throwaway session so JWT_TOKEN stays valid
 echo "Testing POST /auth/logout"
-response=$(curl -s -X POST "$BASE_URL/auth/logout")
+response=$(curl -s -X POST "$BASE_URL/auth/login" \
+    -H "Content-Type: application/x-www-form-urlencoded" \
+    -d "account=aaa@bbb.com&password=123456")
+LOGOUT_TOKEN=$(echo "$response" | grep -o '"tokens":"[^"]*"' | cut -d'"' -f4)
+response=$(curl -s -X POST "$BASE_URL/auth/logout" -H "Authorization: Bearer $LOGOUT_TOKEN")
 check_response "$response" "ok" "Logout should return 'ok'"
+response=$(curl -s "$BASE_URL/authenticated" -H "Authorization: Bearer $LOGOUT_TOKEN")
+check_response "$response" "Session revoked" "Logged out token should be rejected"
 
 # Register (POST /auth/register)
 echo "Testing POST /auth/register"
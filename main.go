@@ -2,13 +2,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/venkatvghub/code-push-server-go/config"
 	"github.com/venkatvghub/code-push-server-go/middleware"
 	"github.com/venkatvghub/code-push-server-go/models"
 	"github.com/venkatvghub/code-push-server-go/routes"
+	"github.com/venkatvghub/code-push-server-go/services"
 	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
@@ -39,9 +42,6 @@ var db *gorm.DB
 }*/
 
 func setupStaticRoutes(r *gin.Engine) {
-	if utils.Config.Storage.Type == "local" {
-		r.Static("/download", utils.Config.Storage.Local.StorageDir)
-	}
 	// Static files
 	r.Static("/static", "./static")
 	r.LoadHTMLGlob("static/*.html")
@@ -50,6 +50,12 @@ func setupStaticRoutes(r *gin.Engine) {
 func main() {
 	// Initialize configuration
 	cfg := config.LoadConfig()
+	if cfg.Storage.CDNBaseURL != "" && os.Getenv("DOWNLOAD_URL_TTL") != "" {
+		log.Println("CDN_BASE_URL is set, so DOWNLOAD_URL_TTL is ignored and download URLs are not signed")
+	}
+	if err := utils.CheckDownloadSigning(context.Background(), utils.NewStorage()); err != nil {
+		log.Fatal(err)
+	}
 
 	// Database connection
 	db = config.InitDB(&cfg.DB)
@@ -58,14 +64,34 @@ func main() {
 	err := db.AutoMigrate(
 		&models.App{}, &models.Collaborator{}, &models.Deployment{}, &models.DeploymentHistory{},
 		&models.DeploymentVersion{}, &models.Package{}, &models.PackageDiff{}, &models.PackageMetrics{},
+		&models.PackageReleaseNote{},
 		&models.UserToken{}, &models.User{}, &models.Version{}, &models.LogReportDeploy{}, &models.LogReportDownload{},
+		&models.UserSession{}, &models.LoginAttempt{}, &models.RecoveryCode{},
+		&models.DeviceCode{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.Invite{},
+		&models.Organization{}, &models.OrgMember{}, &models.AuditLog{},
+		&models.Webhook{}, &models.WebhookDelivery{}, &models.UploadSession{}, &models.Blob{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	// Blobs are no longer reference counted.
+	if db.Migrator().HasColumn(&models.Blob{}, "ref_count") {
+		if err := db.Migrator().DropColumn(&models.Blob{}, "ref_count"); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+	}
+	services.NewAdminService(db).Bootstrap()
+	go services.NewWebhookService(db).Run()
+	go services.NewUploadService(db).Run()
+	if cfg.Storage.GCInterval > 0 {
+		go services.NewGCService(db).Run(cfg.Storage.GCInterval, cfg.Storage.GCGrace)
+	}
 
 	// Initialize Gin router
 	r := gin.Default()
+	// Org apps are addressed as "org/app"; clients send the slash as %2F.
+	r.UseRawPath = true
+	r.UnescapePathValues = true
 	r.Use(middleware.LoggerMiddleware())
 
 	// Static files
@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -45,11 +46,31 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 			tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
 				return []byte(cfg.JWT.TokenSecret), nil
 			})
-			if err != nil || !tkn.Valid {
+			if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
+				c.JSON(401, gin.H{"error": "Token expired"})
+				c.Abort()
+				return
+			}
+			// Tokens minted before sessions existed carry no exp/jti and never expired.
+			if err != nil || !tkn.Valid || claims.ExpiresAt == 0 || claims.Id == "" {
+				c.JSON(401, gin.H{"error": "Invalid token"})
+				c.Abort()
+				return
+			}
+
+			sessionID, err := strconv.ParseUint(claims.Id, 10, 64)
+			if err != nil {
 				c.JSON(401, gin.H{"error": "Invalid token"})
 				c.Abort()
 				return
 			}
+			var session models.UserSession
+			if err := db.Where("id = ? AND uid = ? AND expires_at > ?", sessionID, claims.UID, time.Now()).
+				First(&session).Error; err != nil {
+				c.JSON(401, gin.H{"error": "Session revoked"})
+				c.Abort()
+				return
+			}
 
 			if err := db.Where("id = ?", claims.UID).First(&user).Error; err != nil {
 				c.JSON(401, gin.H{"error": "User not found"})
@@ -62,6 +83,7 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+			c.Set("sessionID", session.ID)
 		} else { // Auth token or Basic auth
 			var tokenModel models.UserToken
 			if err := db.Where("tokens = ? AND expires_at > ?", token, time.Now()).First(&tokenModel).Error; err != nil {
@@ -77,7 +99,26 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
+		if user.IsDisabled == 1 {
+			c.JSON(403, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user", user)
 		c.Next()
 	}
 }
+
+// AdminMiddleware must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet("user").(models.User)
+		if !ok || user.IsAdmin != 1 {
+			c.JSON(403, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -8,13 +8,15 @@ import (
 )
 
 type App struct {
-	ID            uint `gorm:"primaryKey"`
-	Name          string
-	UID           uint64
-	OS            uint8
-	Platform      uint8
-	IsUseDiffText uint8
-	UpdatedAt     time.Time
-	CreatedAt     time.Time
-	DeletedAt     gorm.DeletedAt
+	ID               uint `gorm:"primaryKey"`
+	Name             string
+	UID              uint64
+	OrgID            uint `gorm:"index"` // 0 for apps owned by a personal account
+	OS               uint8
+	Platform         uint8
+	IsUseDiffText    uint8
+	RequireTwoFactor uint8
+	UpdatedAt        time.Time
+	CreatedAt        time.Time
+	DeletedAt        gorm.DeletedAt
 }
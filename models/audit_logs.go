@@ -0,0 +1,71 @@
+// models/audit_logs.go
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be changed or deleted")
+
+// AuditLog records one management action. Rows are append-only: the hooks
+// below refuse updates and deletes made through GORM.
+type AuditLog struct {
+	ID         uint64 `gorm:"primaryKey"`
+	ActorID    uint64 `gorm:"index"` // 0 when the actor is not authenticated, e.g. a failed login
+	ActorEmail string
+	Action     string `gorm:"index"`
+	AppID      uint   `gorm:"index"`
+	TargetType string
+	Target     string
+	Before     string // JSON, empty when not applicable
+	After      string // JSON, empty when not applicable
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (l *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (l *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// Audited actions.
+const (
+	AuditLogin               = "auth.login"
+	AuditLoginFailed         = "auth.login_failed"
+	AuditLogout              = "auth.logout"
+	AuditLogoutAll           = "auth.logout_all"
+	AuditPasswordReset       = "auth.password_reset"
+	AuditPasswordChange      = "user.password_change"
+	AuditTwoFactorEnable     = "user.two_factor_enable"
+	AuditTwoFactorDisable    = "user.two_factor_disable"
+	AuditOIDCLink            = "user.oidc_link"
+	AuditOIDCUnlink          = "user.oidc_unlink"
+	AuditAppCreate           = "app.create"
+	AuditAppDelete           = "app.delete"
+	AuditAppRename           = "app.rename"
+	AuditAppTransfer         = "app.transfer"
+	AuditAppTwoFactor        = "app.two_factor"
+	AuditCollaboratorAdd     = "app.collaborator_add"
+	AuditCollaboratorInvite  = "app.collaborator_invite"
+	AuditDeploymentCreate    = "deployment.create"
+	AuditRelease             = "deployment.release"
+	AuditPromote             = "deployment.promote"
+	AuditRollback            = "deployment.rollback"
+	AuditAccessKeyCreate     = "access_key.create"
+	AuditAccessKeyDelete     = "access_key.delete"
+	AuditOrgCreate           = "org.create"
+	AuditOrgDelete           = "org.delete"
+	AuditOrgMemberSet        = "org.member_set"
+	AuditOrgMemberRemove     = "org.member_remove"
+	AuditAdminUserUpdate     = "admin.user_update"
+	AuditAdminPasswordReset  = "admin.password_reset"
+	AuditAdminTwoFactorReset = "admin.two_factor_reset"
+	AuditAdminUnlock         = "admin.unlock"
+)
@@ -0,0 +1,17 @@
+// models/blobs.go
+package models
+
+import "time"
+
+// Blob is a stored object addressed by the SHA-256 of its content, shared by
+// every package and diff with that content. UpdatedAt is when it was last
+// stored or reused; garbage collection deletes blobs no live package or
+// diff refers to, unless they were used since the collection was planned.
+type Blob struct {
+	ID        uint   `gorm:"primaryKey"`
+	Hash      string `gorm:"uniqueIndex"`
+	Key       string `gorm:"uniqueIndex"`
+	Size      int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
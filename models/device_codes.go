@@ -0,0 +1,26 @@
+// models/device_codes.go
+package models
+
+import "time"
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeConsumed = "consumed"
+)
+
+// DeviceCode is one CLI login via the device authorization flow: the CLI
+// polls with DeviceCode while a signed-in user approves UserCode in the browser.
+type DeviceCode struct {
+	ID           uint64 `gorm:"primaryKey"`
+	DeviceCode   string `gorm:"uniqueIndex"` // sha256 of the code held by the CLI
+	UserCode     string `gorm:"uniqueIndex"`
+	ClientName   string
+	UID          uint64
+	Status       string
+	ExpiresAt    time.Time
+	LastPolledAt time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,22 @@
+// models/invites.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invite lets an app owner add a collaborator who has no account yet.
+// Accepting it registers the account and soft-deletes the row.
+type Invite struct {
+	ID        uint64 `gorm:"primaryKey"`
+	AppID     uint   `gorm:"index"`
+	Email     string `gorm:"index"`
+	Roles     string
+	InvitedBy uint64
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
@@ -0,0 +1,15 @@
+// models/login_attempts.go
+package models
+
+import "time"
+
+// LoginAttempt tracks consecutive failed logins for one key, which is either
+// an account ("account:<id or name>") or a source address ("ip:<addr>").
+type LoginAttempt struct {
+	ID          uint64 `gorm:"primaryKey"`
+	Key         string `gorm:"uniqueIndex"`
+	Failures    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+	CreatedAt   time.Time
+}
@@ -0,0 +1,37 @@
+// models/organizations.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization owns apps on behalf of a team. Its apps are addressed as
+// "org/app" and every member inherits access according to their role.
+type Organization struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"index"`
+	CreatedBy uint64
+	UpdatedAt time.Time
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+type OrgMember struct {
+	ID        uint64 `gorm:"primaryKey"`
+	OrgID     uint   `gorm:"index"`
+	UID       uint64 `gorm:"index"`
+	Role      string
+	UpdatedAt time.Time
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+// Org owners and admins manage members and own every org app; members
+// collaborate on them.
+const (
+	OrgRoleOwner  = "Owner"
+	OrgRoleAdmin  = "Admin"
+	OrgRoleMember = "Member"
+)
@@ -13,27 +13,33 @@ type Package struct {
 	DeploymentID        uint
 	Description         string
 	PackageHash         string
-	BlobURL             string
-	Size                uint
-	ManifestBlobURL     string
-	ReleaseMethod       string
-	Label               string
-	OriginalLabel       string
-	OriginalDeployment  string
-	UpdatedAt           time.Time
-	CreatedAt           time.Time
-	ReleasedBy          uint64
-	IsMandatory         uint8
-	IsDisabled          uint8
-	Rollout             uint8
-	DeletedAt           gorm.DeletedAt
+	// BlobKey is the storage key of the package zip. Download URLs are built
+	// from it when needed; BlobURL is only kept on packages whose URL could
+	// not be converted to a key.
+	BlobKey            string
+	BlobURL            string
+	Size               uint
+	ManifestBlobKey    string
+	ManifestBlobURL    string
+	ReleaseMethod      string
+	Label              string
+	OriginalLabel      string
+	OriginalDeployment string
+	UpdatedAt          time.Time
+	CreatedAt          time.Time
+	ReleasedBy         uint64
+	IsMandatory        uint8
+	IsDisabled         uint8
+	Rollout            uint8
+	DeletedAt          gorm.DeletedAt
 }
 
 type PackageDiff struct {
 	ID                     uint `gorm:"primaryKey"`
 	PackageID              uint
 	DiffAgainstPackageHash string
-	DiffBlobURL            string
+	DiffBlobKey            string
+	DiffBlobURL            string // only kept when it could not be converted to a key
 	DiffSize               uint
 	UpdatedAt              time.Time
 	CreatedAt              time.Time
@@ -51,3 +57,22 @@ type PackageMetrics struct {
 	CreatedAt  time.Time
 	DeletedAt  gorm.DeletedAt
 }
+
+// PackageReleaseNote is a localized "what's new" text of a package.
+// Package.Description stays the default for clients whose locale has none.
+type PackageReleaseNote struct {
+	ID        uint `gorm:"primaryKey"`
+	PackageID uint `gorm:"index"`
+	Locale    string
+	Title     string
+	Body      string
+	Format    string `gorm:"default:text"`
+	UpdatedAt time.Time
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+const (
+	ReleaseNoteFormatText     = "text"
+	ReleaseNoteFormatMarkdown = "markdown"
+)
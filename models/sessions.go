@@ -0,0 +1,24 @@
+// models/sessions.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserSession backs a dashboard login. The access JWT carries the session ID
+// as its jti, so soft-deleting the row revokes every token issued for it.
+type UserSession struct {
+	ID           uint64 `gorm:"primaryKey"`
+	UID          uint64 `gorm:"index"`
+	RefreshToken string `gorm:"uniqueIndex"` // sha256 of the refresh token
+	AckHash      string
+	IP           string
+	UserAgent    string
+	ExpiresAt    time.Time
+	LastUsedAt   time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt
+}
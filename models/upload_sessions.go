@@ -0,0 +1,29 @@
+// models/upload_sessions.go
+package models
+
+import "time"
+
+const (
+	UploadSessionOpen      = "open"
+	UploadSessionFinalized = "finalized"
+)
+
+// UploadSession is a package uploaded in chunks. The chunks are appended to
+// a file in the temp dir; Received is how much of it has been acknowledged,
+// so an interrupted client resumes from there, even after a restart.
+// Direct uploads go straight to storage under StagingKey instead.
+type UploadSession struct {
+	ID           string `gorm:"primaryKey"`
+	AppID        uint
+	DeploymentID uint
+	UID          uint64
+	FileName     string
+	Size         int64  // announced size of the whole file
+	SHA256       string // optional hex checksum of the whole file, checked on finalize
+	Received     int64
+	StagingKey   string
+	Status       string
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
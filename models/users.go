@@ -22,12 +22,56 @@ type UserToken struct {
 }
 
 type User struct {
+	ID                  uint64 `gorm:"primaryKey"`
+	Username            string
+	Password            string
+	Email               string
+	Identical           string
+	AckCode             string
+	TOTPSecret          string
+	TOTPEnabled         uint8
+	TOTPLastStep        int64  // last accepted TOTP time step, to reject replayed codes
+	AuthSource          string `gorm:"default:local"`
+	OIDCLinked          uint8  // set when the owner lets single sign-on log in to a local account
+	PendingVerification uint8  // set until a self-registered address is confirmed
+	IsAdmin             uint8
+	IsDisabled          uint8
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+// Where an account authenticates. SSO accounts get an unusable local password.
+const (
+	AuthSourceLocal = "local"
+	AuthSourceOIDC  = "oidc"
+	AuthSourceLDAP  = "ldap"
+)
+
+// RecoveryCode is a single-use 2FA fallback; using it soft-deletes the row.
+type RecoveryCode struct {
+	ID        uint64 `gorm:"primaryKey"`
+	UID       uint64 `gorm:"index"`
+	CodeHash  string
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+// PasswordReset is a single-use "forgot password" link; using it soft-deletes the row.
+type PasswordReset struct {
+	ID        uint64 `gorm:"primaryKey"`
+	UID       uint64 `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+// EmailVerification is a single-use address confirmation link; using it soft-deletes the row.
+type EmailVerification struct {
 	ID        uint64 `gorm:"primaryKey"`
-	Username  string
-	Password  string
-	Email     string
-	Identical string
-	AckCode   string
+	UID       uint64 `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
 }
@@ -0,0 +1,73 @@
+// models/webhooks.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an endpoint an app owner registered for release lifecycle
+// events. Secret signs every payload so receivers can verify the sender.
+// Chat formats post a readable summary to an incoming webhook instead of
+// the raw event.
+type Webhook struct {
+	ID           uint64 `gorm:"primaryKey"`
+	AppID        uint   `gorm:"index"`
+	DeploymentID uint   // only events of this deployment, 0 for every event of the app
+	URL          string
+	Secret       string
+	Format       string `gorm:"default:json"`
+	Events       string // comma separated event names, empty for all events
+	IsActive     uint8  `gorm:"default:1"`
+	CreatedBy    uint64
+	UpdatedAt    time.Time
+	CreatedAt    time.Time
+	DeletedAt    gorm.DeletedAt
+}
+
+const (
+	WebhookFormatJSON       = "json"
+	WebhookFormatSlack      = "slack"
+	WebhookFormatTeams      = "teams"
+	WebhookFormatMattermost = "mattermost"
+)
+
+var WebhookFormats = []string{WebhookFormatJSON, WebhookFormatSlack, WebhookFormatTeams, WebhookFormatMattermost}
+
+// WebhookDelivery is one payload queued for one webhook, kept as the
+// delivery log after it succeeds or gives up.
+type WebhookDelivery struct {
+	ID             uint64 `gorm:"primaryKey"`
+	WebhookID      uint64 `gorm:"index"`
+	Event          string
+	Payload        string
+	Status         string `gorm:"index"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastStatusCode int
+	LastError      string
+	DeliveredAt    *time.Time
+	UpdatedAt      time.Time
+	CreatedAt      time.Time
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook events. Ping is only sent by the "send test" endpoint.
+const (
+	WebhookEventRelease      = "release"
+	WebhookEventPromote      = "promote"
+	WebhookEventRollback     = "rollback"
+	WebhookEventCollaborator = "collaborator"
+	WebhookEventPing         = "ping"
+)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []string{
+	WebhookEventRelease, WebhookEventPromote, WebhookEventRollback, WebhookEventCollaborator,
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/venkatvghub/code-push-server-go/controllers"
@@ -11,7 +12,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func setupAuthRoutes(r *gin.Engine, ctrl *controllers.AuthController) {
+func setupAuthRoutes(r *gin.Engine, ctrl *controllers.AuthController, oidcCtrl *controllers.OIDCController, deviceCtrl *controllers.DeviceController) {
 	auth := r.Group("/auth")
 	{
 		auth.GET("/login", func(c *gin.Context) {
@@ -19,11 +20,23 @@ func setupAuthRoutes(r *gin.Engine, ctrl *controllers.AuthController) {
 				"title":        "CodePushServer",
 				"email":        c.Query("email"),
 				"showRegister": utils.Config.Common.AllowRegistration,
+				"showSSO":      oidcCtrl.OIDCSvc.Enabled(),
 			})
 		})
 		auth.GET("/password", func(c *gin.Context) {
 			c.HTML(http.StatusOK, "password.html", gin.H{"title": "CodePushServer"})
 		})
+		auth.GET("/password/forgot", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "forgot.html", gin.H{"title": "CodePushServer"})
+		})
+		auth.GET("/password/reset", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "reset.html", gin.H{"title": "CodePushServer", "token": c.Query("token")})
+		})
+		auth.GET("/verify", ctrl.VerifyEmail)
+		auth.GET("/invite", ctrl.InvitePage)
+		auth.GET("/twoFactor", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "twofactor.html", gin.H{"title": "CodePushServer"})
+		})
 		auth.GET("/register", func(c *gin.Context) {
 			if !utils.Config.Common.AllowRegistration {
 				c.Redirect(http.StatusFound, "/auth/login")
@@ -35,8 +48,23 @@ func setupAuthRoutes(r *gin.Engine, ctrl *controllers.AuthController) {
 			})
 		})
 		auth.POST("/login", ctrl.Login)
-		auth.POST("/logout", ctrl.Logout)
+		auth.POST("/refresh", ctrl.Refresh)
+		auth.POST("/logout", middleware.AuthMiddleware(ctrl.DB), ctrl.Logout)
+		auth.POST("/logout/all", middleware.AuthMiddleware(ctrl.DB), ctrl.LogoutAll)
 		auth.POST("/register", ctrl.Register)
+		auth.POST("/verify/resend", ctrl.ResendVerification)
+		auth.POST("/invite/accept", ctrl.AcceptInvite)
+		auth.POST("/password/forgot", ctrl.ForgotPassword)
+		auth.POST("/password/reset", ctrl.ResetPassword)
+
+		auth.GET("/oidc/login", oidcCtrl.Login)
+		auth.GET("/oidc/callback", oidcCtrl.Callback)
+		auth.POST("/oidc/otp", oidcCtrl.OTP)
+
+		auth.POST("/device/code", deviceCtrl.Code)
+		auth.POST("/device/token", deviceCtrl.Token)
+		auth.GET("/device", middleware.AuthMiddleware(ctrl.DB), deviceCtrl.Lookup)
+		auth.POST("/device/approve", middleware.AuthMiddleware(ctrl.DB), deviceCtrl.Decide)
 	}
 }
 
@@ -47,12 +75,21 @@ func setupIndexRoutes(r *gin.Engine, ctrl *controllers.IndexController) {
 	r.GET("/updateCheck", ctrl.UpdateCheck)
 	r.POST("/reportStatus/download", ctrl.ReportStatusDownload)
 	r.POST("/reportStatus/deploy", ctrl.ReportStatusDeploy)
+	if utils.Config.Storage.Type == "local" {
+		r.GET(strings.TrimSuffix(utils.Config.Storage.Local.Public, "/")+"/*key", ctrl.Download)
+	}
 }
 
 func setupUsersRoutes(r *gin.Engine, ctrl *controllers.UsersController) {
 	users := r.Group("/users")
 	{
 		users.PATCH("/password", middleware.AuthMiddleware(ctrl.DB), ctrl.ChangePassword)
+		users.POST("/twoFactor", middleware.AuthMiddleware(ctrl.DB), ctrl.EnrollTwoFactor)
+		users.POST("/twoFactor/confirm", middleware.AuthMiddleware(ctrl.DB), ctrl.ConfirmTwoFactor)
+		users.POST("/twoFactor/recoveryCodes", middleware.AuthMiddleware(ctrl.DB), ctrl.RegenerateRecoveryCodes)
+		users.DELETE("/twoFactor", middleware.AuthMiddleware(ctrl.DB), ctrl.DisableTwoFactor)
+		users.PUT("/oidc", middleware.AuthMiddleware(ctrl.DB), ctrl.LinkOIDC)
+		users.DELETE("/oidc", middleware.AuthMiddleware(ctrl.DB), ctrl.UnlinkOIDC)
 		// Add other user routes (register, exists, etc.) as needed
 	}
 }
@@ -62,24 +99,28 @@ func setupAccessKeysRoutes(r *gin.Engine, ctrl *controllers.AccessKeysController
 	accessKeys.Use(middleware.AuthMiddleware(ctrl.DB))
 	{
 		accessKeys.POST("", ctrl.CreateAccessKey)
+		accessKeys.DELETE("/:name", ctrl.DeleteAccessKey)
 	}
 }
 
-func setupAccountRoutes(r *gin.Engine, ctrl *controllers.AccountController) {
+func setupAccountRoutes(r *gin.Engine, ctrl *controllers.AccountController, auditCtrl *controllers.AuditController) {
 	account := r.Group("/account")
 	account.Use(middleware.AuthMiddleware(ctrl.DB))
 	{
 		account.GET("/accessKeys", ctrl.GetAccessKeys)
+		account.GET("/audit", auditCtrl.AccountAudit)
 	}
 }
 
-func setupAppsRoutes(r *gin.Engine, ctrl *controllers.AppsController) {
+func setupAppsRoutes(r *gin.Engine, ctrl *controllers.AppsController, auditCtrl *controllers.AuditController) {
 	apps := r.Group("/apps")
 	apps.Use(middleware.AuthMiddleware(ctrl.DB))
 	{
 		apps.POST("", ctrl.AddApp)
 		apps.DELETE("/:appName", ctrl.DeleteApp)
 		apps.PATCH("/:appName", ctrl.RenameApp)
+		apps.PATCH("/:appName/twoFactor", ctrl.SetTwoFactorRequirement)
+		apps.POST("/:appName/transfer/:orgName", ctrl.TransferApp)
 		apps.GET("/:appName/collaborators", ctrl.ListCollaborators)
 		apps.POST("/:appName/collaborators/:email", ctrl.AddCollaborator)
 		apps.POST("/:appName/deployments", ctrl.AddDeployment)
@@ -87,6 +128,63 @@ func setupAppsRoutes(r *gin.Engine, ctrl *controllers.AppsController) {
 		apps.POST("/:appName/deployments/promote", ctrl.PromotePackage) // Changed route
 		apps.POST("/:appName/deployments/:deploymentName/rollback", ctrl.RollbackPackage)
 		apps.POST("/:appName/deployments/:deploymentName/rollback/:label", ctrl.RollbackPackage)
+		apps.GET("/:appName/deployments/:deploymentName/releases/:label/notes", ctrl.GetReleaseNotes)
+		apps.PUT("/:appName/deployments/:deploymentName/releases/:label/notes", ctrl.SetReleaseNotes)
+		apps.GET("/:appName/audit", auditCtrl.AppAudit)
+	}
+}
+
+func setupUploadsRoutes(r *gin.Engine, ctrl *controllers.UploadsController) {
+	uploads := r.Group("/apps/:appName/deployments/:deploymentName/uploads")
+	uploads.Use(middleware.AuthMiddleware(ctrl.DB))
+	{
+		uploads.POST("", ctrl.CreateUpload)
+		uploads.GET("/:uploadID", ctrl.GetUpload)
+		uploads.PUT("/:uploadID", ctrl.PutChunk)
+		uploads.POST("/:uploadID/finalize", ctrl.FinalizeUpload)
+		uploads.DELETE("/:uploadID", ctrl.DeleteUpload)
+	}
+}
+
+func setupWebhooksRoutes(r *gin.Engine, ctrl *controllers.WebhooksController) {
+	webhooks := r.Group("/apps/:appName/webhooks")
+	webhooks.Use(middleware.AuthMiddleware(ctrl.DB))
+	{
+		webhooks.GET("", ctrl.ListWebhooks)
+		webhooks.POST("", ctrl.AddWebhook)
+		webhooks.PATCH("/:webhookID", ctrl.UpdateWebhook)
+		webhooks.DELETE("/:webhookID", ctrl.DeleteWebhook)
+		webhooks.GET("/:webhookID/deliveries", ctrl.ListDeliveries)
+		webhooks.POST("/:webhookID/test", ctrl.TestWebhook)
+	}
+}
+
+func setupOrgsRoutes(r *gin.Engine, ctrl *controllers.OrgsController) {
+	orgs := r.Group("/orgs")
+	orgs.Use(middleware.AuthMiddleware(ctrl.DB))
+	{
+		orgs.POST("", ctrl.AddOrg)
+		orgs.GET("", ctrl.ListOrgs)
+		orgs.GET("/:orgName", ctrl.GetOrg)
+		orgs.DELETE("/:orgName", ctrl.DeleteOrg)
+		orgs.PUT("/:orgName/members/:email", ctrl.SetMember)
+		orgs.DELETE("/:orgName/members/:email", ctrl.RemoveMember)
+	}
+}
+
+func setupAdminRoutes(r *gin.Engine, ctrl *controllers.AdminController, auditCtrl *controllers.AuditController) {
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(ctrl.DB), middleware.AdminMiddleware())
+	{
+		admin.GET("/users", ctrl.ListUsers)
+		admin.GET("/users/:uid", ctrl.GetUser)
+		admin.PATCH("/users/:uid", ctrl.UpdateUser)
+		admin.POST("/users/:uid/passwordReset", ctrl.ForcePasswordReset)
+		admin.DELETE("/users/:uid/twoFactor", ctrl.ResetTwoFactor)
+		admin.POST("/users/:uid/unlock", ctrl.UnlockUser)
+		admin.GET("/apps", ctrl.ListApps)
+		admin.GET("/apps/:appID", ctrl.GetApp)
+		admin.GET("/audit", auditCtrl.AdminAudit)
 	}
 }
 
@@ -99,20 +197,68 @@ func setupIndexV1Routes(r *gin.Engine, ctrl *controllers.IndexV1Controller) {
 	}
 }
 func SetupRoutes(r *gin.Engine, db *gorm.DB) {
-	authCtrl := controllers.AuthController{DB: db}
+	mailer := utils.NewMailer()
+	auditSvc := services.NewAuditService(db)
+	webhookSvc := services.NewWebhookService(db)
+	sessionSvc := services.NewSessionService(db)
+	inviteSvc := services.NewInviteService(db, mailer)
+	twoFactorSvc := services.NewTwoFactorService(db)
+	loginGuard := services.NewLoginGuard(db)
+	resetSvc := services.NewPasswordResetService(db, mailer, sessionSvc)
+	authCtrl := controllers.AuthController{
+		DB:           db,
+		SessionSvc:   sessionSvc,
+		LoginGuard:   loginGuard,
+		TwoFactorSvc: twoFactorSvc,
+		LDAPSvc:      services.NewLDAPService(db),
+		ResetSvc:     resetSvc,
+		VerifySvc:    services.NewEmailVerificationService(db, mailer),
+		InviteSvc:    inviteSvc,
+		AuditSvc:     auditSvc,
+	}
 	indexCtrl := controllers.IndexController{DB: db, ClientSvc: services.NewClientService(db)}
-	usersCtrl := controllers.UsersController{DB: db}
-	accessKeysCtrl := controllers.AccessKeysController{DB: db}
+	usersCtrl := controllers.UsersController{DB: db, SessionSvc: sessionSvc, TwoFactorSvc: twoFactorSvc, AuditSvc: auditSvc}
+	accessKeysCtrl := controllers.AccessKeysController{DB: db, AuditSvc: auditSvc}
 	accountCtrl := controllers.AccountController{DB: db}
+	acctSvc := services.NewAccountService(db)
+	orgSvc := services.NewOrgService(db)
 	appsCtrl := controllers.AppsController{
-		DB:      db,
-		AppSvc:  services.NewAppService(db),
-		AcctSvc: services.NewAccountService(db),
+		DB:         db,
+		AppSvc:     services.NewAppService(db),
+		AcctSvc:    acctSvc,
+		OrgSvc:     orgSvc,
+		InviteSvc:  inviteSvc,
+		AuditSvc:   auditSvc,
+		WebhookSvc: webhookSvc,
 	}
+	uploadsCtrl := controllers.UploadsController{DB: db, Apps: &appsCtrl, UploadSvc: services.NewUploadService(db)}
+	webhooksCtrl := controllers.WebhooksController{DB: db, AppSvc: appsCtrl.AppSvc, AcctSvc: acctSvc, WebhookSvc: webhookSvc}
+	orgsCtrl := controllers.OrgsController{DB: db, OrgSvc: orgSvc, AcctSvc: acctSvc, AuditSvc: auditSvc}
+	auditCtrl := controllers.AuditController{DB: db, AuditSvc: auditSvc, AcctSvc: acctSvc}
 	indexV1Ctrl := controllers.IndexV1Controller{DB: db, ClientSvc: services.NewClientService(db)}
 
+	oidcCtrl := controllers.OIDCController{
+		DB:           db,
+		OIDCSvc:      services.NewOIDCService(db),
+		SessionSvc:   sessionSvc,
+		TwoFactorSvc: twoFactorSvc,
+		LoginGuard:   loginGuard,
+		AuditSvc:     auditSvc,
+	}
+	deviceCtrl := controllers.DeviceController{DB: db, DeviceSvc: services.NewDeviceService(db), AuditSvc: auditSvc}
+	adminCtrl := controllers.AdminController{
+		DB:           db,
+		AdminSvc:     services.NewAdminService(db),
+		OrgSvc:       orgSvc,
+		SessionSvc:   sessionSvc,
+		TwoFactorSvc: twoFactorSvc,
+		ResetSvc:     resetSvc,
+		LoginGuard:   loginGuard,
+		AuditSvc:     auditSvc,
+	}
+
 	//authCtrl.SetupRoutes(r)
-	setupAuthRoutes(r, &authCtrl)
+	setupAuthRoutes(r, &authCtrl, &oidcCtrl, &deviceCtrl)
 	//indexCtrl.SetupRoutes(r)
 	setupIndexRoutes(r, &indexCtrl)
 	//usersCtrl.SetupRoutes(r)
@@ -120,9 +266,13 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 	//accessKeysCtrl.SetupRoutes(r)
 	setupAccessKeysRoutes(r, &accessKeysCtrl)
 	//accountCtrl.SetupRoutes(r)
-	setupAccountRoutes(r, &accountCtrl)
+	setupAccountRoutes(r, &accountCtrl, &auditCtrl)
 	//appsCtrl.SetupRoutes(r)
-	setupAppsRoutes(r, &appsCtrl)
+	setupAppsRoutes(r, &appsCtrl, &auditCtrl)
+	setupOrgsRoutes(r, &orgsCtrl)
+	setupUploadsRoutes(r, &uploadsCtrl)
+	setupWebhooksRoutes(r, &webhooksCtrl)
 	//indexV1Ctrl.SetupRoutes(r)
 	setupIndexV1Routes(r, &indexV1Ctrl)
+	setupAdminRoutes(r, &adminCtrl, &auditCtrl)
 }
@@ -17,15 +17,69 @@ func NewAccountService(db *gorm.DB) *AccountService {
 	return &AccountService{DB: db}
 }
 
+// CollaboratorCan resolves appName ("app" or "org/app") to the caller's
+// collaborator record. Members of an app's organization inherit a role on it;
+// the stronger of the inherited and a direct role wins.
 func (s *AccountService) CollaboratorCan(uid uint64, appName string) (*models.Collaborator, error) {
-	var collaborator models.Collaborator
-	err := s.DB.Joins("JOIN apps ON apps.id = collaborators.app_id").
-		Where("collaborators.uid = ? AND apps.name = ?", uid, appName).
-		First(&collaborator).Error
-	if err != nil {
+	var collaborator *models.Collaborator
+	orgName, name := SplitAppName(appName)
+	if orgName == "" {
+		var direct models.Collaborator
+		err := s.DB.Joins("JOIN apps ON apps.id = collaborators.app_id").
+			Where("collaborators.uid = ? AND apps.name = ? AND apps.org_id = 0", uid, name).
+			First(&direct).Error
+		if err == nil {
+			collaborator = &direct
+		}
+	} else {
+		collaborator = s.orgCollaborator(uid, orgName, name)
+	}
+	if collaborator == nil {
 		return nil, errors.New("App " + appName + " not exists or permission denied")
 	}
-	return &collaborator, nil
+	if err := s.checkTwoFactor(uid, collaborator.AppID, appName); err != nil {
+		return nil, err
+	}
+	return collaborator, nil
+}
+
+func (s *AccountService) orgCollaborator(uid uint64, orgName, name string) *models.Collaborator {
+	var app models.App
+	if err := s.DB.Joins("JOIN organizations ON organizations.id = apps.org_id AND organizations.deleted_at IS NULL").
+		Where("organizations.name = ? AND apps.name = ?", orgName, name).
+		First(&app).Error; err != nil {
+		return nil
+	}
+
+	var direct models.Collaborator
+	hasDirect := s.DB.Where("app_id = ? AND uid = ?", app.ID, uid).First(&direct).Error == nil
+	if hasDirect && direct.Roles == "Owner" {
+		return &direct
+	}
+	inherited := AppRoleForOrgRole(NewOrgService(s.DB).MemberRole(app.OrgID, uid))
+	if inherited == "" {
+		if hasDirect {
+			return &direct
+		}
+		return nil
+	}
+	return &models.Collaborator{AppID: app.ID, UID: uid, Roles: inherited}
+}
+
+// checkTwoFactor refuses access to apps that require 2FA unless the user has it enabled.
+func (s *AccountService) checkTwoFactor(uid uint64, appID uint, appName string) error {
+	var app models.App
+	if err := s.DB.Select("id, require_two_factor").First(&app, appID).Error; err != nil {
+		return errors.New("App " + appName + " not exists or permission denied")
+	}
+	if app.RequireTwoFactor != 1 {
+		return nil
+	}
+	var user models.User
+	if err := s.DB.Select("id, totp_enabled").First(&user, uid).Error; err != nil || user.TOTPEnabled != 1 {
+		return errors.New("App " + appName + " requires two-factor authentication, enable it for your account first")
+	}
+	return nil
 }
 
 func (s *AccountService) OwnerCan(uid uint64, appName string) (*models.Collaborator, error) {
@@ -93,5 +147,5 @@ func (s *AccountService) Login(account, password string) (*models.User, error) {
 	return &user, nil
 }
 
-// TODO: Note: Registration-related methods (sendRegisterCode, checkRegisterCode, register) are omitted since Redis is removed
-// and we don't need email verification for this English-only version per your requirements.
+// Note: Registration-related methods (sendRegisterCode, checkRegisterCode) are replaced by the
+// link-based EmailVerificationService in verification.go, which needs no Redis.
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+type AdminService struct {
+	DB *gorm.DB
+}
+
+func NewAdminService(db *gorm.DB) *AdminService {
+	return &AdminService{DB: db}
+}
+
+// Bootstrap promotes BOOTSTRAP_ADMIN_EMAIL to admin as long as the server has
+// no admin yet, so the first admin can be created without database access.
+func (s *AdminService) Bootstrap() {
+	email := utils.Config.Common.BootstrapAdmin
+	if email == "" {
+		return
+	}
+	var admins int64
+	if err := s.DB.Model(&models.User{}).Where("is_admin = ?", 1).Count(&admins).Error; err != nil || admins > 0 {
+		return
+	}
+	if err := s.SetAdminByEmail(email, true); err != nil {
+		log.Printf("Failed to bootstrap admin %s: %v", email, err)
+		return
+	}
+	log.Printf("Promoted %s to admin", email)
+}
+
+func (s *AdminService) SetAdminByEmail(email string, admin bool) error {
+	res := s.DB.Model(&models.User{}).Where("email = ?", email).Update("is_admin", utils.BoolToUint8(admin))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New(email + " does not exist")
+	}
+	return nil
+}
+
+func (s *AdminService) FindUser(uid uint64) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, uid).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+// ListUsers pages through users whose email or username contains query.
+func (s *AdminService) ListUsers(query string, page, pageSize int) ([]models.User, int64, error) {
+	db := s.DB.Model(&models.User{})
+	if query != "" {
+		like := "%" + strings.ToLower(query) + "%"
+		db = db.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ?", like, like)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if err := db.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *AdminService) UpdateUser(uid uint64, fields map[string]interface{}) error {
+	return s.DB.Model(&models.User{}).Where("id = ?", uid).Updates(fields).Error
+}
+
+// ListApps pages through every app, whatever its owner, matching query by name.
+func (s *AdminService) ListApps(query string, page, pageSize int) ([]models.App, int64, error) {
+	db := s.DB.Model(&models.App{})
+	if query != "" {
+		db = db.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(query)+"%")
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var apps []models.App
+	if err := db.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&apps).Error; err != nil {
+		return nil, 0, err
+	}
+	return apps, total, nil
+}
+
+// FindUsers loads the users with the given IDs, keyed by ID.
+func (s *AdminService) FindUsers(uids []uint64) (map[uint64]models.User, error) {
+	var users []models.User
+	if err := s.DB.Where("id IN ?", uids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint64]models.User, len(users))
+	for _, u := range users {
+		result[u.ID] = u
+	}
+	return result, nil
+}
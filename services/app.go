@@ -2,6 +2,7 @@ package services
 
 import (
 	"archive/zip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -18,14 +19,18 @@ import (
 )
 
 type AppService struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Storage utils.Storage
+	Blobs   *BlobService
 }
 
 func NewAppService(db *gorm.DB) *AppService {
-	return &AppService{DB: db}
+	blobs := NewBlobService(db)
+	return &AppService{DB: db, Storage: blobs.Storage, Blobs: blobs}
 }
 
 func (s *AppService) CreateDiffPackagesByLastNums(appID uint, pkg *models.Package, diffNums int) error {
+	ctx := context.Background()
 	var packages []models.Package
 	cfg := config.LoadConfig()
 	if err := s.DB.Where("deployment_id = ?", pkg.DeploymentID).
@@ -36,43 +41,36 @@ func (s *AppService) CreateDiffPackagesByLastNums(appID uint, pkg *models.Packag
 		return err
 	}
 
-	storage := utils.NewStorage()
-	newPkgPath := pkg.BlobURL[len(storage.GetFileURL("")):] // Remove base URL prefix
+	newPkgKey := s.PackageKey(pkg)
 
 	for _, oldPkg := range packages {
 		if oldPkg.ID == pkg.ID || oldPkg.PackageHash == pkg.PackageHash {
 			continue
 		}
 
-		oldPkgPath := oldPkg.BlobURL[len(storage.GetFileURL("")):]
+		oldPkgKey := s.PackageKey(&oldPkg)
 		diffFileName := fmt.Sprintf("%d_%s_%s_diff.zip", pkg.ID, pkg.PackageHash[:8], oldPkg.PackageHash[:8])
 		tempDiffPath := filepath.Join(cfg.Common.TempDir, diffFileName)
 
-		err := s.createSimpleDiffZip(newPkgPath, oldPkgPath, tempDiffPath)
+		err := s.createSimpleDiffZip(ctx, newPkgKey, oldPkgKey, tempDiffPath)
 		if err != nil {
 			log.Printf("Failed to create diff for package %d against %d: %v", pkg.ID, oldPkg.ID, err)
-			continue
-		}
-
-		if err := storage.UploadFile(tempDiffPath, diffFileName); err != nil {
-			log.Printf("Failed to upload diff file: %v", err)
 			os.Remove(tempDiffPath)
 			continue
 		}
-		os.Remove(tempDiffPath)
 
-		diffInfo, err := os.Stat(tempDiffPath)
+		blob, err := s.Blobs.PutFile(ctx, tempDiffPath)
+		os.Remove(tempDiffPath)
 		if err != nil {
-			log.Printf("Failed to stat diff file: %v", err)
+			log.Printf("Failed to upload diff file: %v", err)
 			continue
 		}
 
-		diffURL := storage.GetFileURL(diffFileName)
 		diff := models.PackageDiff{
 			PackageID:              pkg.ID,
 			DiffAgainstPackageHash: oldPkg.PackageHash,
-			DiffBlobURL:            diffURL,
-			DiffSize:               uint(diffInfo.Size()),
+			DiffBlobKey:            blob.Key,
+			DiffSize:               uint(blob.Size),
 		}
 		if err := s.DB.Create(&diff).Error; err != nil {
 			log.Printf("Failed to save diff to database: %v", err)
@@ -82,7 +80,10 @@ func (s *AppService) CreateDiffPackagesByLastNums(appID uint, pkg *models.Packag
 	return nil
 }
 
-func (s *AppService) createSimpleDiffZip(newPath, oldPath, diffPath string) error {
+func (s *AppService) createSimpleDiffZip(ctx context.Context, newKey, oldKey, diffPath string) error {
+	if newKey == "" || oldKey == "" {
+		return errors.New("package is not in the configured storage")
+	}
 	diffFile, err := os.Create(diffPath)
 	if err != nil {
 		return err
@@ -90,40 +91,53 @@ func (s *AppService) createSimpleDiffZip(newPath, oldPath, diffPath string) erro
 	defer diffFile.Close()
 
 	writer := zip.NewWriter(diffFile)
-	defer writer.Close()
-
-	newFile, err := os.Open(newPath)
-	if err != nil {
-		return err
-	}
-	defer newFile.Close()
-	newWriter, err := writer.Create("new.zip")
-	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(newWriter, newFile); err != nil {
-		return err
+	for name, key := range map[string]string{"new.zip": newKey, "old.zip": oldKey} {
+		r, err := s.Storage.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		w, err := writer.Create(name)
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
 	}
 
-	oldFile, err := os.Open(oldPath)
-	if err != nil {
-		return err
-	}
-	defer oldFile.Close()
-	oldWriter, err := writer.Create("old.zip")
-	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(oldWriter, oldFile); err != nil {
-		return err
+	return writer.Close()
+}
+
+// SplitAppName splits "org/app" into its parts. Personal app names have no org.
+func SplitAppName(appName string) (string, string) {
+	if org, name, ok := strings.Cut(appName, "/"); ok {
+		return org, name
 	}
+	return "", appName
+}
 
-	return nil
+// AppFullName is how users address an app: "org/app", or the bare name for personal apps.
+func AppFullName(orgName, name string) string {
+	if orgName == "" {
+		return name
+	}
+	return orgName + "/" + name
 }
 
-func (s *AppService) AddApp(uid uint64, name, os, platform string) (*models.App, error) {
+// AddApp creates an app owned by orgID, or by uid personally when orgID is 0.
+// uid becomes the owning collaborator of personal apps only; org apps are
+// owned through the organization's roles.
+func (s *AppService) AddApp(uid uint64, orgID uint, name, os, platform string) (*models.App, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, errors.New("invalid app name")
+	}
 	var existingApp models.App
-	if err := s.DB.Where("uid = ? AND name = ?", uid, name).First(&existingApp).Error; err == nil {
+	scope := s.DB.Where("org_id = ? AND name = ?", orgID, name)
+	if orgID == 0 {
+		scope = scope.Where("uid = ?", uid)
+	}
+	if err := scope.First(&existingApp).Error; err == nil {
 		return nil, errors.New(name + " exists")
 	}
 
@@ -136,12 +150,16 @@ func (s *AppService) AddApp(uid uint64, name, os, platform string) (*models.App,
 	app := models.App{
 		Name:     name,
 		UID:      uid,
+		OrgID:    orgID,
 		OS:       osVal,
 		Platform: platformVal,
 	}
 	if err := s.DB.Create(&app).Error; err != nil {
 		return nil, err
 	}
+	if orgID != 0 {
+		return &app, nil
+	}
 
 	collaborator := models.Collaborator{
 		AppID: app.ID,
@@ -155,9 +173,17 @@ func (s *AppService) AddApp(uid uint64, name, os, platform string) (*models.App,
 	return &app, nil
 }
 
+// FindAppByName looks up "org/app" in the organization, or a bare name among
+// the personal apps of uid.
 func (s *AppService) FindAppByName(uid uint64, name string) (*models.App, error) {
 	var app models.App
-	if err := s.DB.Where("uid = ? AND name = ?", uid, name).First(&app).Error; err != nil {
+	orgName, appName := SplitAppName(name)
+	db := s.DB.Where("uid = ? AND org_id = 0 AND name = ?", uid, appName)
+	if orgName != "" {
+		db = s.DB.Joins("JOIN organizations ON organizations.id = apps.org_id AND organizations.deleted_at IS NULL").
+			Where("organizations.name = ? AND apps.name = ?", orgName, appName)
+	}
+	if err := db.First(&app).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -194,43 +220,72 @@ func (s *AppService) AddDeployment(appID uint, name string) (*models.Deployment,
 	return &deployment, nil
 }
 
-func (s *AppService) ReleasePackage(appID, deploymentID uint, filePath, description string, uid uint64, isMandatory bool) (*models.Package, error) {
-	var deployment models.Deployment
-	if err := s.DB.First(&deployment, deploymentID).Error; err != nil {
-		return nil, errors.New("deployment not found")
-	}
-
-	fileInfo, err := os.Stat(filePath)
+func (s *AppService) ReleasePackage(ctx context.Context, appID, deploymentID uint, filePath, description string, uid uint64, isMandatory bool, notes []ReleaseNote) (*models.Package, error) {
+	blob, err := s.Blobs.PutFile(ctx, filePath)
 	if err != nil {
 		return nil, err
 	}
+	return s.ReleaseStoredPackage(appID, deploymentID, blob.Key, blob.Size, blob.Hash, description, uid, isMandatory, notes)
+}
 
-	storage := utils.NewStorage()
-	key := utils.RandToken(10) + "_" + filepath.Base(filePath)
-	if err := storage.UploadFile(filePath, key); err != nil {
-		return nil, err
+// TouchPackage marks the blob of pkg as used, for a promoted or rolled back
+// copy of it.
+func (s *AppService) TouchPackage(pkg *models.Package) error {
+	return s.Blobs.Touch(s.PackageKey(pkg))
+}
+
+// PackageKey is the storage key of the zip of pkg, or "" if it only has a
+// URL outside the configured storage.
+func (s *AppService) PackageKey(pkg *models.Package) string {
+	if pkg.BlobKey != "" {
+		return pkg.BlobKey
+	}
+	return utils.KeyFromURL(s.Storage, pkg.BlobURL)
+}
+
+// PackageURL is the URL the zip of pkg can be downloaded from, signed like
+// the ones update checks return.
+func (s *AppService) PackageURL(ctx context.Context, pkg *models.Package) (string, error) {
+	if pkg.BlobKey != "" {
+		return utils.DownloadURL(ctx, s.Storage, pkg.BlobKey)
+	}
+	return pkg.BlobURL, nil
+}
+
+// ReleaseStoredPackage releases a package zip that is already in storage
+// under key. The
+// package, its release notes and the deployment are saved in one transaction.
+func (s *AppService) ReleaseStoredPackage(appID, deploymentID uint, key string, size int64, packageHash, description string, uid uint64, isMandatory bool, notes []ReleaseNote) (*models.Package, error) {
+	var deployment models.Deployment
+	if err := s.DB.First(&deployment, deploymentID).Error; err != nil {
+		return nil, errors.New("deployment not found")
 	}
 
 	label := "v" + fmt.Sprintf("%d", deployment.LabelID+1)
 	pkg := models.Package{
 		DeploymentID:  deploymentID,
 		Description:   description,
-		PackageHash:   utils.Md5(filePath),
-		BlobURL:       storage.GetFileURL(key),
-		Size:          uint(fileInfo.Size()),
+		PackageHash:   packageHash,
+		BlobKey:       key,
+		Size:          uint(size),
 		ReleaseMethod: "Upload",
 		Label:         label,
 		ReleasedBy:    uid,
 		IsMandatory:   utils.BoolToUint8(isMandatory),
 		Rollout:       100,
 	}
-	if err := s.DB.Create(&pkg).Error; err != nil {
-		return nil, err
-	}
-
-	deployment.LabelID++
-	deployment.LastDeploymentVersionID = pkg.ID
-	if err := s.DB.Save(&deployment).Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pkg).Error; err != nil {
+			return err
+		}
+		if err := saveReleaseNotes(tx, pkg.ID, notes); err != nil {
+			return err
+		}
+		deployment.LabelID++
+		deployment.LastDeploymentVersionID = pkg.ID
+		return tx.Save(&deployment).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
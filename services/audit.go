@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"gorm.io/gorm"
+)
+
+type AuditService struct {
+	DB *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// AuditEvent describes one action. Before and After are stored as JSON.
+type AuditEvent struct {
+	ActorID    uint64
+	ActorEmail string
+	IP         string
+	UserAgent  string
+	Action     string
+	AppID      uint
+	TargetType string
+	Target     string
+	Before     interface{}
+	After      interface{}
+}
+
+// Record appends event to the audit log. Failures are logged rather than
+// returned so that auditing never undoes an action that already happened.
+func (s *AuditService) Record(event AuditEvent) {
+	entry := models.AuditLog{
+		ActorID:    event.ActorID,
+		ActorEmail: event.ActorEmail,
+		Action:     event.Action,
+		AppID:      event.AppID,
+		TargetType: event.TargetType,
+		Target:     event.Target,
+		Before:     auditJSON(event.Before),
+		After:      auditJSON(event.After),
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+	}
+	if err := s.DB.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit event %s by %s: %v", event.Action, event.ActorEmail, err)
+	}
+}
+
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// AuditFilter narrows a query. Zero values match everything.
+type AuditFilter struct {
+	AppID      uint
+	ActorID    uint64
+	ActorEmail string
+	Action     string
+	From       time.Time
+	To         time.Time
+}
+
+// Query returns one page of matching entries, newest first, and the total count.
+func (s *AuditService) Query(filter AuditFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	db := s.DB.Model(&models.AuditLog{})
+	if filter.AppID != 0 {
+		db = db.Where("app_id = ?", filter.AppID)
+	}
+	if filter.ActorID != 0 {
+		db = db.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ActorEmail != "" {
+		db = db.Where("actor_email = ?", filter.ActorEmail)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		db = db.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		db = db.Where("created_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.AuditLog
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
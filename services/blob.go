@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlobService stores package and diff zips once per content. Packages and
+// diffs refer to a blob by its key; GCService deletes the ones left unused.
+type BlobService struct {
+	DB      *gorm.DB
+	Storage utils.Storage
+}
+
+func NewBlobService(db *gorm.DB) *BlobService {
+	return &BlobService{DB: db, Storage: utils.NewStorage()}
+}
+
+// BlobKey is the storage key of content with the hex SHA-256 hash.
+func BlobKey(hash string) string {
+	return "blobs/" + hash
+}
+
+// PutFile stores the file at filePath unless its content is stored already.
+func (s *BlobService) PutFile(ctx context.Context, filePath string) (*models.Blob, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	return s.store(ctx, sum, size, func(key string) error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return s.Storage.Put(ctx, key, f, size)
+	})
+}
+
+// Adopt stores the blob with hash by copying the verified object under
+// stagingKey into place within storage, unless the content is stored
+// already. The staging object is left for the caller to delete.
+func (s *BlobService) Adopt(ctx context.Context, stagingKey, hash string, size int64) (*models.Blob, error) {
+	copier, ok := s.Storage.(utils.ObjectCopier)
+	if !ok {
+		return nil, errors.New("storage cannot copy objects")
+	}
+	return s.store(ctx, hash, size, func(key string) error {
+		return copier.Copy(ctx, stagingKey, key)
+	})
+}
+
+// ErrBlobCollected is returned when content to reuse was deleted by
+// garbage collection in the meantime.
+var ErrBlobCollected = errors.New("package content is no longer stored, release it again")
+
+// Touch marks the blob under key as used now, for a package that reuses the
+// content of another one, so a collection that has not deleted it yet keeps
+// it. Keys stored before blobs existed have no blob and are ignored.
+func (s *BlobService) Touch(key string) error {
+	if !strings.HasPrefix(key, BlobKey("")) {
+		return nil
+	}
+	res := s.DB.Model(&models.Blob{}).Where("key = ?", key).Update("updated_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBlobCollected
+	}
+	return nil
+}
+
+// store marks the blob with hash as used and calls put to write its object
+// if storage does not have it. The row is upserted first, so it stays
+// locked until the object is in place; GCService locks the same row before
+// deleting an object.
+func (s *BlobService) store(ctx context.Context, hash string, size int64, put func(key string) error) (*models.Blob, error) {
+	var blob models.Blob
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		blob = models.Blob{Hash: hash, Key: BlobKey(hash), Size: size}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"updated_at": time.Now()}),
+		}).Create(&blob).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("hash = ?", hash).First(&blob).Error; err != nil {
+			return err
+		}
+		_, err := s.Storage.Stat(ctx, blob.Key)
+		if errors.Is(err, utils.ErrObjectNotFound) {
+			return put(blob.Key)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
@@ -1,21 +1,27 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log"
 
 	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/gorm"
 )
 
 type ClientService struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Storage utils.Storage
 }
 
 func NewClientService(db *gorm.DB) *ClientService {
-	return &ClientService{DB: db}
+	return &ClientService{DB: db, Storage: utils.NewStorage()}
 }
 
-func (s *ClientService) UpdateCheck(deploymentKey, appVersion, label, packageHash, clientUniqueID string) (map[string]interface{}, error) {
+// UpdateCheck describes the update for a client. When the package has a
+// release note for locale, it replaces the default description.
+func (s *ClientService) UpdateCheck(ctx context.Context, deploymentKey, appVersion, label, packageHash, clientUniqueID, locale string) (map[string]interface{}, error) {
 	var deployment models.Deployment
 	if err := s.DB.Where("deployment_key = ?", deploymentKey).First(&deployment).Error; err != nil {
 		return nil, errors.New("invalid deployment key")
@@ -39,21 +45,53 @@ func (s *ClientService) UpdateCheck(deploymentKey, appVersion, label, packageHas
 		}, nil
 	}
 
+	downloadURL, err := s.downloadURL(ctx, &pkg)
+	if err != nil {
+		log.Printf("Failed to sign download URL of package %d: %v", pkg.ID, err)
+		return nil, errors.New("package unavailable")
+	}
+
+	description := pkg.Description
+	var releaseNotes map[string]interface{}
+	if note := s.releaseNoteFor(pkg.ID, locale); note != nil {
+		description = note.Body
+		releaseNotes = map[string]interface{}{
+			"locale": note.Locale,
+			"title":  note.Title,
+			"body":   note.Body,
+			"format": note.Format,
+		}
+	}
+
 	return map[string]interface{}{
-		"isAvailable": true,
-		"downloadUrl": pkg.BlobURL,
-		"description": pkg.Description,
-		"label":       pkg.Label,
-		"packageHash": pkg.PackageHash,
-		"packageSize": pkg.Size,
-		"isMandatory": pkg.IsMandatory == 1,
-		"appVersion":  appVersion,
-		"packageId":   pkg.ID,
-		"rollout":     pkg.Rollout,
-		"isDisabled":  pkg.IsDisabled == 1,
+		"isAvailable":  true,
+		"downloadUrl":  downloadURL,
+		"description":  description,
+		"releaseNotes": releaseNotes,
+		"label":        pkg.Label,
+		"packageHash":  pkg.PackageHash,
+		"packageSize":  pkg.Size,
+		"isMandatory":  pkg.IsMandatory == 1,
+		"appVersion":   appVersion,
+		"packageId":    pkg.ID,
+		"rollout":      pkg.Rollout,
+		"isDisabled":   pkg.IsDisabled == 1,
 	}, nil
 }
 
+// downloadURL is where clients fetch the zip of pkg. Packages that only have
+// a URL outside the configured storage are served from that URL.
+func (s *ClientService) downloadURL(ctx context.Context, pkg *models.Package) (string, error) {
+	key := pkg.BlobKey
+	if key == "" {
+		key = utils.KeyFromURL(s.Storage, pkg.BlobURL)
+	}
+	if key == "" {
+		return pkg.BlobURL, nil
+	}
+	return utils.DownloadURL(ctx, s.Storage, key)
+}
+
 func (s *ClientService) ReportStatusDownload(deploymentKey, label, clientUniqueID string) error {
 	var deployment models.Deployment
 	if err := s.DB.Where("deployment_key = ?", deploymentKey).First(&deployment).Error; err != nil {
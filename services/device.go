@@ -0,0 +1,164 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	deviceCodeInterval = 5 * time.Second
+	deviceAccessKeyTTL = 30 * 24 * time.Hour
+	// userCodeAlphabet avoids vowels and look-alike characters.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// Errors returned by DeviceService.Poll, named after their RFC 8628 codes.
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+)
+
+// DeviceService lets the CLI obtain an access key without copy-pasting:
+// the CLI starts a device login, the user approves its code on the tokens
+// page, and the CLI's next poll receives a fresh access key.
+type DeviceService struct {
+	DB      *gorm.DB
+	AcctSvc *AccountService
+}
+
+func NewDeviceService(db *gorm.DB) *DeviceService {
+	return &DeviceService{DB: db, AcctSvc: NewAccountService(db)}
+}
+
+type DeviceAuthorization struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresIn  int
+	Interval   int
+}
+
+// Start issues a new device code. Expired ones are deleted first; the CLI
+// only ever gets expired_token for them, which a missing row gives as well.
+func (s *DeviceService) Start(clientName string) (*DeviceAuthorization, error) {
+	if err := s.DB.Where("expires_at <= ?", time.Now()).Delete(&models.DeviceCode{}).Error; err != nil {
+		return nil, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return nil, err
+	}
+	deviceCode := utils.RandSecret(32)
+	if err := s.DB.Create(&models.DeviceCode{
+		DeviceCode: utils.Sha256(deviceCode),
+		UserCode:   userCode,
+		ClientName: clientName,
+		Status:     models.DeviceCodePending,
+		ExpiresAt:  time.Now().Add(deviceCodeTTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &DeviceAuthorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		ExpiresIn:  int(deviceCodeTTL.Seconds()),
+		Interval:   int(deviceCodeInterval.Seconds()),
+	}, nil
+}
+
+// Lookup returns the pending request for userCode so the page can show which
+// client is asking before the user approves it.
+func (s *DeviceService) Lookup(userCode string) (*models.DeviceCode, error) {
+	var code models.DeviceCode
+	if err := s.DB.Where("user_code = ? AND status = ? AND expires_at > ?",
+		NormalizeUserCode(userCode), models.DeviceCodePending, time.Now()).First(&code).Error; err != nil {
+		return nil, errors.New("invalid or expired code")
+	}
+	return &code, nil
+}
+
+// Decide approves or denies a pending request on behalf of uid.
+func (s *DeviceService) Decide(uid uint64, userCode string, approve bool) error {
+	status := models.DeviceCodeDenied
+	if approve {
+		status = models.DeviceCodeApproved
+	}
+	res := s.DB.Model(&models.DeviceCode{}).
+		Where("user_code = ? AND status = ? AND expires_at > ?", NormalizeUserCode(userCode), models.DeviceCodePending, time.Now()).
+		Updates(map[string]interface{}{"status": status, "uid": uid})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("invalid or expired code")
+	}
+	return nil
+}
+
+// Poll is called by the CLI until it gets an access key or a terminal error.
+func (s *DeviceService) Poll(deviceCode string) (*models.UserToken, error) {
+	var code models.DeviceCode
+	if err := s.DB.Where("device_code = ?", utils.Sha256(deviceCode)).First(&code).Error; err != nil {
+		return nil, ErrExpiredToken
+	}
+	if time.Now().After(code.ExpiresAt) || code.Status == models.DeviceCodeConsumed {
+		return nil, ErrExpiredToken
+	}
+	if code.Status == models.DeviceCodeDenied {
+		return nil, ErrAccessDenied
+	}
+
+	tooFast := time.Since(code.LastPolledAt) < deviceCodeInterval
+	s.DB.Model(&code).Update("last_polled_at", time.Now())
+	if code.Status == models.DeviceCodePending {
+		if tooFast {
+			return nil, ErrSlowDown
+		}
+		return nil, ErrAuthorizationPending
+	}
+
+	// Approved: consume it exactly once, even if two polls race.
+	res := s.DB.Model(&models.DeviceCode{}).Where("id = ? AND status = ?", code.ID, models.DeviceCodeApproved).
+		Update("status", models.DeviceCodeConsumed)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrExpiredToken
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("Login-%d", now.UnixMilli())
+	return s.AcctSvc.CreateAccessKey(code.UID, utils.RandToken(40), name, code.ClientName,
+		"Device login from "+code.ClientName, deviceAccessKeyTTL.Milliseconds())
+}
+
+func NormalizeUserCode(userCode string) string {
+	code := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(userCode), "-", ""))
+	if len(code) == 8 {
+		return code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+func randomUserCode() (string, error) {
+	b := make([]byte, 8)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
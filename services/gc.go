@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tempFilePattern matches what releases, diffs and uploads leave in TempDir,
+// which may be shared with other programs: RandToken prefixed uploads and
+// zips, package staging dirs, spooled S3 uploads and diff zips.
+var tempFilePattern = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]_.+|package-.+|s3-put-.+|\d+_\w{8}_\w{8}_diff\.zip)$`)
+
+// gcKeyPattern matches the storage keys this server writes, so a bucket
+// shared with other data only loses objects of ours: blobs, staged direct
+// uploads, and the RandToken prefixed packages and diff zips stored before
+// blobs existed.
+var gcKeyPattern = regexp.MustCompile(`^(blobs/.+|staging/.+|[0-9a-f]{8}-[0-9a-f]_[^/]+|\d+_\w{8}_\w{8}_diff\.zip)$`)
+
+// GCPlan is what a collection deletes. Temp files are keyed by their path.
+// Objects referenced again after Cutoff are kept by Collect.
+type GCPlan struct {
+	Objects   []utils.ObjectInfo
+	TempFiles []utils.ObjectInfo
+	Bytes     int64
+	Cutoff    time.Time
+}
+
+// GCService deletes storage objects no live package, diff or upload refers
+// to, and temp files left behind by failed releases.
+type GCService struct {
+	DB      *gorm.DB
+	Storage utils.Storage
+}
+
+func NewGCService(db *gorm.DB) *GCService {
+	return &GCService{DB: db, Storage: utils.NewStorage()}
+}
+
+// Plan lists the unreferenced objects and temp files older than grace.
+// Packages of deleted apps and deployments, and deleted packages, do not
+// count as references.
+func (s *GCService) Plan(ctx context.Context, grace time.Duration) (*GCPlan, error) {
+	referenced, err := s.referencedKeys()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s.Storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-grace)
+	plan := &GCPlan{Cutoff: cutoff}
+	for _, obj := range objects {
+		if gcKeyPattern.MatchString(obj.Key) && !referenced[obj.Key] && obj.ModTime.Before(cutoff) {
+			plan.Objects = append(plan.Objects, obj)
+			plan.Bytes += obj.Size
+		}
+	}
+
+	temp, err := s.staleTempFiles(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	plan.TempFiles = temp
+	for _, f := range temp {
+		plan.Bytes += f.Size
+	}
+	return plan, nil
+}
+
+// Collect deletes what plan lists, and the blobs of deleted objects. Since
+// deduplication reuses old objects without rewriting them, every object is
+// checked again with its blob row locked, and kept if a live package, diff
+// or upload refers to it or its blob was stored or reused after
+// plan.Cutoff. It returns how many objects were kept.
+func (s *GCService) Collect(ctx context.Context, plan *GCPlan) (int, error) {
+	var kept int
+	for _, obj := range plan.Objects {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var blob models.Blob
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", obj.Key).First(&blob).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && blob.UpdatedAt.After(plan.Cutoff) {
+				kept++
+				return nil
+			}
+			used, err := s.inUse(tx, obj.Key)
+			if err != nil || used {
+				if used {
+					kept++
+				}
+				return err
+			}
+			if err := s.Storage.Delete(ctx, obj.Key); err != nil {
+				return fmt.Errorf("delete %s: %w", obj.Key, err)
+			}
+			return tx.Where("key = ?", obj.Key).Delete(&models.Blob{}).Error
+		})
+		if err != nil {
+			return kept, err
+		}
+	}
+	for _, f := range plan.TempFiles {
+		if err := os.RemoveAll(f.Key); err != nil {
+			return kept, err
+		}
+	}
+	return kept, nil
+}
+
+// Run collects garbage every interval until the process exits.
+func (s *GCService) Run(interval, grace time.Duration) {
+	for {
+		time.Sleep(interval)
+		plan, err := s.Plan(context.Background(), grace)
+		var kept int
+		if err == nil {
+			kept, err = s.Collect(context.Background(), plan)
+		}
+		if err != nil {
+			log.Printf("Storage garbage collection failed: %v", err)
+			continue
+		}
+		log.Printf("Storage garbage collection removed %d objects and %d temp files, kept %d objects in use again", len(plan.Objects)-kept, len(plan.TempFiles), kept)
+	}
+}
+
+// livePackages selects the packages of live apps and deployments.
+func livePackages(db *gorm.DB) *gorm.DB {
+	return db.Table("packages").
+		Joins("JOIN deployments ON deployments.id = packages.deployment_id AND deployments.deleted_at IS NULL").
+		Joins("JOIN apps ON apps.id = deployments.app_id AND apps.deleted_at IS NULL").
+		Where("packages.deleted_at IS NULL")
+}
+
+// inUse reports whether a live package, diff or upload refers to key, by
+// key or by the URL older rows have instead.
+func (s *GCService) inUse(tx *gorm.DB, key string) (bool, error) {
+	url := s.Storage.URL(key)
+	var count int64
+	if err := livePackages(tx).
+		Where("packages.blob_key = ? OR packages.manifest_blob_key = ? OR packages.blob_url = ? OR packages.manifest_blob_url = ?", key, key, url, url).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	if err := livePackages(tx).
+		Joins("JOIN package_diffs ON package_diffs.package_id = packages.id AND package_diffs.deleted_at IS NULL").
+		Where("package_diffs.diff_blob_key = ? OR package_diffs.diff_blob_url = ?", key, url).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	err := tx.Model(&models.UploadSession{}).Where("staging_key = ?", key).Count(&count).Error
+	return count > 0, err
+}
+
+func (s *GCService) referencedKeys() (map[string]bool, error) {
+	live := livePackages(s.DB)
+
+	var packages []models.Package
+	if err := live.Session(&gorm.Session{}).
+		Select("packages.blob_key, packages.blob_url, packages.manifest_blob_key, packages.manifest_blob_url").
+		Find(&packages).Error; err != nil {
+		return nil, err
+	}
+	var diffs []models.PackageDiff
+	if err := live.Session(&gorm.Session{}).
+		Joins("JOIN package_diffs ON package_diffs.package_id = packages.id AND package_diffs.deleted_at IS NULL").
+		Select("package_diffs.diff_blob_key, package_diffs.diff_blob_url").
+		Find(&diffs).Error; err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	var foreign int
+	add := func(key, url string) {
+		if key == "" && url != "" {
+			if key = utils.KeyFromURL(s.Storage, url); key == "" {
+				foreign++
+			}
+		}
+		if key != "" {
+			keys[key] = true
+		}
+	}
+	for _, pkg := range packages {
+		add(pkg.BlobKey, pkg.BlobURL)
+		add(pkg.ManifestBlobKey, pkg.ManifestBlobURL)
+	}
+	for _, diff := range diffs {
+		add(diff.DiffBlobKey, diff.DiffBlobURL)
+	}
+	if foreign > 0 {
+		// Their objects would look unreferenced.
+		return nil, fmt.Errorf("%d package URLs do not belong to the configured storage, convert them with \"storage keys\" first", foreign)
+	}
+
+	var stagingKeys []string
+	if err := s.DB.Model(&models.UploadSession{}).Where("staging_key <> ''").Pluck("staging_key", &stagingKeys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range stagingKeys {
+		keys[key] = true
+	}
+	return keys, nil
+}
+
+func (s *GCService) staleTempFiles(cutoff time.Time) ([]utils.ObjectInfo, error) {
+	var files []utils.ObjectInfo
+	dir := utils.Config.Common.TempDir
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !tempFilePattern.MatchString(entry.Name()) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			files = append(files, utils.ObjectInfo{Key: filepath.Join(dir, entry.Name()), Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+
+	// Chunks of uploads whose session is gone.
+	parts, err := os.ReadDir(uploadDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range parts {
+		id, ok := strings.CutSuffix(entry.Name(), ".part")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		var count int64
+		if err := s.DB.Model(&models.UploadSession{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			files = append(files, utils.ObjectInfo{Key: filepath.Join(uploadDir(), entry.Name()), Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+	return files, nil
+}
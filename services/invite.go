@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// InviteService lets owners add collaborators who have no account yet, so
+// teams can grow while self-registration stays closed.
+type InviteService struct {
+	DB     *gorm.DB
+	Mailer utils.Mailer
+}
+
+func NewInviteService(db *gorm.DB, mailer utils.Mailer) *InviteService {
+	return &InviteService{DB: db, Mailer: mailer}
+}
+
+// Invite mails an invitation to join appName, replacing any pending one for
+// the same app and address.
+func (s *InviteService) Invite(appID uint, appName, email, roles string, inviter *models.User) error {
+	token := utils.RandSecret(32)
+	ttl := utils.Config.Common.InviteTTL
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("app_id = ? AND email = ?", appID, email).Delete(&models.Invite{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Invite{
+			AppID:     appID,
+			Email:     email,
+			Roles:     roles,
+			InvitedBy: inviter.ID,
+			TokenHash: utils.Sha256(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := utils.Config.Common.PublicURL + "/auth/invite?token=" + url.QueryEscape(token)
+	body := inviter.Email + " invited you to collaborate on the app " + appName + " on CodePushServer.\n\n" +
+		"Open this link to accept and set up your account:\n" + link + "\n\n" +
+		"The invitation expires in " + ttl.String() + "."
+	return s.Mailer.Send(email, "You have been invited to "+appName+" on CodePushServer", body)
+}
+
+// Lookup returns the pending invite for token.
+func (s *InviteService) Lookup(token string) (*models.Invite, error) {
+	var invite models.Invite
+	if err := s.DB.Where("token_hash = ? AND expires_at > ?", utils.Sha256(token), time.Now()).
+		First(&invite).Error; err != nil {
+		return nil, errors.New("invitation is invalid or has expired")
+	}
+	return &invite, nil
+}
+
+// Accept consumes the invite, registering the invitee with password unless
+// an account for the address already exists, and adds the collaborator row.
+// The returned flag reports whether a new account was created.
+func (s *InviteService) Accept(token, password string) (*models.User, bool, error) {
+	invite, err := s.Lookup(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var user models.User
+	created := false
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(invite)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("invitation is invalid or has expired")
+		}
+
+		err := tx.Where("email = ?", invite.Email).First(&user).Error
+		if err == gorm.ErrRecordNotFound {
+			if len(password) < 6 {
+				return errors.New("password must be at least 6 characters")
+			}
+			// The invitee proved ownership of the address by opening the link.
+			user = models.User{
+				Email:     invite.Email,
+				Username:  invite.Email,
+				Password:  utils.HashPassword(password),
+				Identical: utils.RandToken(9),
+				AckCode:   utils.RandToken(5),
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		} else if err != nil {
+			return err
+		}
+
+		var existing int64
+		tx.Model(&models.Collaborator{}).Where("app_id = ? AND uid = ?", invite.AppID, user.ID).Count(&existing)
+		if existing > 0 {
+			return nil
+		}
+		return tx.Create(&models.Collaborator{
+			AppID: invite.AppID,
+			UID:   user.ID,
+			Roles: invite.Roles,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
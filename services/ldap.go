@@ -0,0 +1,209 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCredentials means the directory rejected the account or password,
+// as opposed to the directory being unreachable or misconfigured.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// ErrLDAPAccountConflict means the directory email belongs to an account that
+// was not created by a directory login, which a directory login never uses.
+var ErrLDAPAccountConflict = errors.New("the directory email belongs to an account that does not log in through the directory")
+
+// LDAPIdentity is what a successful directory bind tells us about the user.
+type LDAPIdentity struct {
+	DN       string
+	Username string
+	Email    string
+	Roles    []string
+}
+
+// LDAPAdminRole in LDAP_GROUP_ROLES makes members of that group server admins.
+const LDAPAdminRole = "admin"
+
+func (i *LDAPIdentity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type LDAPService struct {
+	DB *gorm.DB
+}
+
+func NewLDAPService(db *gorm.DB) *LDAPService {
+	return &LDAPService{DB: db}
+}
+
+func (s *LDAPService) Enabled() bool {
+	return utils.Config.LDAP.URL != ""
+}
+
+// Authenticate finds the entry for account with the service bind, then binds
+// as that entry with password.
+func (s *LDAPService) Authenticate(account, password string) (*LDAPIdentity, error) {
+	if password == "" {
+		// An empty password would be an unauthenticated bind, which succeeds.
+		return nil, ErrInvalidCredentials
+	}
+	cfg := utils.Config.LDAP
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.BindDN != "" {
+		err = conn.Bind(cfg.BindDN, cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("service bind failed: %v", err)
+	}
+
+	filter := strings.ReplaceAll(cfg.SearchFilter, "{account}", ldap.EscapeFilter(account))
+	res, err := conn.Search(ldap.NewSearchRequest(
+		cfg.SearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 10, false, filter,
+		[]string{cfg.UsernameAttribute, cfg.EmailAttribute, cfg.GroupAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %v", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := res.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	identity := &LDAPIdentity{
+		DN:       entry.DN,
+		Username: entry.GetAttributeValue(cfg.UsernameAttribute),
+		Email:    entry.GetAttributeValue(cfg.EmailAttribute),
+		Roles:    mapGroupRoles(entry.GetAttributeValues(cfg.GroupAttribute)),
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("directory entry %s has no %s attribute", entry.DN, cfg.EmailAttribute)
+	}
+	if cfg.GroupRoles != "" && len(identity.Roles) == 0 {
+		// With a mapping configured, only members of a mapped group may log in.
+		return nil, ErrInvalidCredentials
+	}
+	return identity, nil
+}
+
+// SyncUser creates or refreshes the local record for a directory login.
+// existing is the account the login name already resolved to, if any. The
+// directory email decides which account is logged in: a login name that is
+// also someone else's local username must not log in as that account. Only
+// accounts created by a directory login are used; a local or single sign-on
+// account with the same email, which may be an admin or use TOTP, is left
+// alone and ErrLDAPAccountConflict returned.
+func (s *LDAPService) SyncUser(identity *LDAPIdentity, existing *models.User) (*models.User, error) {
+	user := existing
+	if user != nil && (user.Email != identity.Email || user.AuthSource != models.AuthSourceLDAP) {
+		user = nil
+	}
+	if user == nil {
+		var found models.User
+		err := s.DB.Where("email = ?", identity.Email).First(&found).Error
+		if err == nil {
+			if found.AuthSource != models.AuthSourceLDAP {
+				return nil, ErrLDAPAccountConflict
+			}
+			user = &found
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+
+	username := identity.Username
+	if username == "" {
+		username = identity.Email
+	}
+	if user == nil {
+		user = &models.User{
+			Email:      identity.Email,
+			Username:   username,
+			Password:   utils.HashPassword(utils.RandSecret(32)), // unusable, the directory owns the password
+			Identical:  utils.RandToken(9),
+			AckCode:    utils.RandToken(5),
+			AuthSource: models.AuthSourceLDAP,
+			IsAdmin:    utils.BoolToUint8(identity.HasRole(LDAPAdminRole)),
+		}
+		if err := s.DB.Create(user).Error; err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	// Without an admin group mapped, the admin flag is managed on the server.
+	isAdmin := user.IsAdmin
+	if ldapAdminMapped() {
+		isAdmin = utils.BoolToUint8(identity.HasRole(LDAPAdminRole))
+	}
+	if user.Username != username || user.IsAdmin != isAdmin {
+		user.Username = username
+		user.IsAdmin = isAdmin
+		if err := s.DB.Model(user).Updates(map[string]interface{}{
+			"username": user.Username, "is_admin": user.IsAdmin,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ldapAdminMapped reports whether LDAP_GROUP_ROLES maps a group to LDAPAdminRole.
+func ldapAdminMapped() bool {
+	for _, mapping := range strings.Split(utils.Config.LDAP.GroupRoles, ";") {
+		if role, _, ok := strings.Cut(strings.TrimSpace(mapping), ":"); ok && strings.TrimSpace(role) == LDAPAdminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// mapGroupRoles translates group DNs into roles using LDAP_GROUP_ROLES.
+func mapGroupRoles(groups []string) []string {
+	var roles []string
+	for _, mapping := range strings.Split(utils.Config.LDAP.GroupRoles, ";") {
+		role, groupDN, ok := strings.Cut(strings.TrimSpace(mapping), ":")
+		if !ok {
+			continue
+		}
+		for _, group := range groups {
+			if strings.EqualFold(strings.TrimSpace(groupDN), group) {
+				roles = append(roles, strings.TrimSpace(role))
+				break
+			}
+		}
+	}
+	return roles
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptStore persists failed-login counters. The database store is
+// shared by every node; the memory store only suits a single instance.
+type LoginAttemptStore interface {
+	Get(key string) (*models.LoginAttempt, error) // nil when the key has no failures
+	// Increment atomically counts a failure of key and returns the count.
+	// A counter last failed before expiredBefore starts over, unlocked.
+	Increment(key string, expiredBefore time.Time) (int, error)
+	Lock(key string, until time.Time) error
+	Delete(key string) error
+}
+
+func NewLoginAttemptStore(db *gorm.DB) LoginAttemptStore {
+	switch utils.Config.Common.LoginGuard.Store {
+	case "memory":
+		return NewMemoryLoginAttemptStore()
+	default: // "database" or unrecognized falls back to database
+		return NewDBLoginAttemptStore(db)
+	}
+}
+
+// DBLoginAttemptStore implementation
+type DBLoginAttemptStore struct {
+	DB *gorm.DB
+}
+
+func NewDBLoginAttemptStore(db *gorm.DB) LoginAttemptStore {
+	return &DBLoginAttemptStore{DB: db}
+}
+
+func (s *DBLoginAttemptStore) Get(key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.DB.Where("key = ?", key).First(&attempt).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *DBLoginAttemptStore) Increment(key string, expiredBefore time.Time) (int, error) {
+	expired := gorm.Expr("login_attempts.updated_at < ?", expiredBefore)
+	attempt := models.LoginAttempt{Key: key, Failures: 1}
+	err := s.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":     gorm.Expr("CASE WHEN ? THEN 1 ELSE login_attempts.failures + 1 END", expired),
+			"locked_until": gorm.Expr("CASE WHEN ? THEN excluded.locked_until ELSE login_attempts.locked_until END", expired),
+			"updated_at":   gorm.Expr("excluded.updated_at"),
+		}),
+	}, clause.Returning{Columns: []clause.Column{{Name: "failures"}}}).Create(&attempt).Error
+	return attempt.Failures, err
+}
+
+func (s *DBLoginAttemptStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&models.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (s *DBLoginAttemptStore) Delete(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// MemoryLoginAttemptStore implementation
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+func (s *MemoryLoginAttemptStore) Get(key string) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Increment(key string, expiredBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok || attempt.UpdatedAt.Before(expiredBefore) {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.UpdatedAt = time.Now()
+	s.attempts[key] = attempt
+	return attempt.Failures, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if attempt, ok := s.attempts[key]; ok {
+		attempt.LockedUntil = until
+		s.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// LoginGuard applies TRY_LOGIN_TIMES: once an account or source address
+// reaches its limit, further logins are refused for a lockout that doubles
+// with every additional failure, up to LOGIN_LOCKOUT_MAX.
+type LoginGuard struct {
+	Store LoginAttemptStore
+}
+
+func NewLoginGuard(db *gorm.DB) *LoginGuard {
+	return &LoginGuard{Store: NewLoginAttemptStore(db)}
+}
+
+// LoginLockedError is returned while a key is locked out.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("Too many failed login attempts, try again in %d seconds", int(e.RetryAfter.Seconds())+1)
+}
+
+func AccountLoginKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (g *LoginGuard) enabled() bool {
+	return utils.Config.Common.TryLoginTimes > 0
+}
+
+// Check returns a *LoginLockedError if either key is currently locked.
+func (g *LoginGuard) Check(accountKey, ipKey string) error {
+	if !g.enabled() {
+		return nil
+	}
+	for _, key := range []string{accountKey, ipKey} {
+		attempt, err := g.Store.Get(key)
+		if err != nil {
+			return err
+		}
+		if attempt != nil && time.Now().Before(attempt.LockedUntil) {
+			return &LoginLockedError{RetryAfter: time.Until(attempt.LockedUntil)}
+		}
+	}
+	return nil
+}
+
+// Failure records a failed login and returns how many attempts the account
+// has left before it is locked, and the lockout it has just earned, if any.
+func (g *LoginGuard) Failure(accountKey, ipKey string) (int, time.Duration, error) {
+	if !g.enabled() {
+		return 0, 0, nil
+	}
+	cfg := utils.Config.Common
+	remaining, lockout, err := g.fail(accountKey, cfg.TryLoginTimes)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, ipLockout, err := g.fail(ipKey, cfg.LoginGuard.IPTryTimes); err != nil {
+		return 0, 0, err
+	} else if ipLockout > lockout {
+		lockout = ipLockout
+	}
+	return remaining, lockout, nil
+}
+
+// Success clears the account counter. The address counter is left to decay
+// so a valid login cannot be used to reset a password-spraying source.
+func (g *LoginGuard) Success(accountKey string) error {
+	if !g.enabled() {
+		return nil
+	}
+	return g.Store.Delete(accountKey)
+}
+
+// Unlock clears the failure counter and lockout of a key.
+func (g *LoginGuard) Unlock(key string) error {
+	return g.Store.Delete(key)
+}
+
+func (g *LoginGuard) fail(key string, limit int) (int, time.Duration, error) {
+	if limit <= 0 {
+		return 0, 0, nil
+	}
+	cfg := utils.Config.Common.LoginGuard
+	// Counters expire once a full max lockout has passed without failures.
+	failures, err := g.Store.Increment(key, time.Now().Add(-cfg.MaxLockout))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lockout time.Duration
+	if failures >= limit {
+		lockout = cfg.BaseLockout << uint(min(failures-limit, 30))
+		if lockout <= 0 || lockout > cfg.MaxLockout {
+			lockout = cfg.MaxLockout
+		}
+		if err := g.Store.Lock(key, time.Now().Add(lockout)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return max(limit-failures, 0), lockout, nil
+}
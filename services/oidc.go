@@ -0,0 +1,376 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// OIDCService implements the authorization code flow (with PKCE) against the
+// provider configured in OIDC_ISSUER_URL. Any issuer that serves a discovery
+// document works, including local mock issuers used for testing.
+type OIDCService struct {
+	DB     *gorm.DB
+	Client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDCService(db *gorm.DB) *OIDCService {
+	return &OIDCService{DB: db, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *OIDCService) Enabled() bool {
+	return utils.Config.OIDC.IssuerURL != ""
+}
+
+// PKCEChallenge derives the S256 code challenge for a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to.
+func (s *OIDCService) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	d, err := s.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	cfg := utils.Config.OIDC
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", cfg.RedirectURL)
+	q.Set("scope", cfg.Scopes)
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Authenticate exchanges an authorization code, verifies the returned ID
+// token and resolves it to a local user, provisioning one if allowed.
+func (s *OIDCService) Authenticate(code, verifier, nonce string) (*models.User, error) {
+	d, err := s.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	cfg := utils.Config.OIDC
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+	form.Set("code_verifier", verifier)
+	resp, err := s.Client.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token exchange failed: %s", tokenResp.Error)
+	}
+
+	claims, err := s.verifyIDToken(d, tokenResp.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	email, _ := claims[cfg.EmailClaim].(string)
+	if email == "" && d.UserinfoEndpoint != "" {
+		if info, err := s.userinfo(d, tokenResp.AccessToken); err == nil {
+			email, _ = info[cfg.EmailClaim].(string)
+			if v, ok := info["email_verified"]; ok {
+				claims["email_verified"] = v
+			}
+		}
+	}
+	if email == "" {
+		return nil, errors.New("identity provider did not return an email address")
+	}
+	// A missing claim is not trusted either: the email decides the account.
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return nil, errors.New("email address is not verified by the identity provider")
+	}
+	return s.findOrProvision(email)
+}
+
+// findOrProvision resolves email to the account single sign-on logs in to.
+// Accounts created otherwise, which may have their own password and TOTP,
+// are only used once their owner linked them (OIDCLinked).
+func (s *OIDCService) findOrProvision(email string) (*models.User, error) {
+	var user models.User
+	err := s.DB.Where("email = ?", email).First(&user).Error
+	if err == nil {
+		if user.AuthSource != models.AuthSourceOIDC && user.OIDCLinked != 1 {
+			return nil, errors.New("an account for " + email + " already exists, log in with its password and link single sign-on to it first")
+		}
+		return &user, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if !utils.Config.OIDC.AutoProvision {
+		return nil, errors.New("no account exists for " + email)
+	}
+
+	user = models.User{
+		Email:      email,
+		Username:   email,
+		Password:   utils.HashPassword(utils.RandSecret(32)), // unusable, SSO accounts log in through the provider
+		Identical:  utils.RandToken(9),
+		AckCode:    utils.RandToken(5),
+		AuthSource: models.AuthSourceOIDC,
+	}
+	if err := s.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// OIDCOTPTTL is how long a provider login waits for the TOTP code of an
+// account with two-factor authentication enabled.
+const OIDCOTPTTL = 5 * time.Minute
+
+var errPendingOTP = errors.New("login session expired, please try again")
+
+// PendingOTP returns a value the browser holds while the TOTP code of user is
+// asked for after a provider login. It names the user, expires after
+// OIDCOTPTTL and is signed with TOKEN_SECRET.
+func (s *OIDCService) PendingOTP(user *models.User) string {
+	uid := strconv.FormatUint(user.ID, 10)
+	expires := strconv.FormatInt(time.Now().Add(OIDCOTPTTL).Unix(), 10)
+	return uid + "." + expires + "." + pendingOTPSignature(uid, expires, user.AckCode)
+}
+
+// PendingOTPUser returns the user a PendingOTP value was issued for.
+func (s *OIDCService) PendingOTPUser(value string) (*models.User, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return nil, errPendingOTP
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, errPendingOTP
+	}
+	var user models.User
+	if err := s.DB.Where("id = ?", parts[0]).First(&user).Error; err != nil {
+		return nil, errPendingOTP
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(pendingOTPSignature(parts[0], parts[1], user.AckCode))) {
+		return nil, errPendingOTP
+	}
+	return &user, nil
+}
+
+func pendingOTPSignature(uid, expires, ackCode string) string {
+	mac := hmac.New(sha256.New, []byte(utils.Config.JWT.TokenSecret))
+	mac.Write([]byte("oidc-otp:" + uid + ":" + expires + ":" + ackCode))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *OIDCService) verifyIDToken(d *oidcDiscovery, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return s.getKey(d, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+	if !claims.VerifyIssuer(d.Issuer, true) {
+		return nil, errors.New("invalid id token issuer")
+	}
+	if !audienceContains(claims["aud"], utils.Config.OIDC.ClientID) {
+		return nil, errors.New("invalid id token audience")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id token has no expiry")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid id token nonce")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *OIDCService) userinfo(d *oidcDiscovery, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, d.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo returned %d", resp.StatusCode)
+	}
+	info := map[string]interface{}{}
+	return info, json.NewDecoder(resp.Body).Decode(&info)
+}
+
+func (s *OIDCService) getDiscovery() (*oidcDiscovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+	if !s.Enabled() {
+		return nil, errors.New("single sign-on is not configured")
+	}
+
+	issuer := strings.TrimSuffix(utils.Config.OIDC.IssuerURL, "/")
+	resp, err := s.Client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %d", resp.StatusCode)
+	}
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", d.Issuer, issuer)
+	}
+	s.discovery = &d
+	return s.discovery, nil
+}
+
+// getKey returns the verification key for kid, refetching the JWKS once when
+// the key is unknown so provider key rotation is picked up.
+func (s *OIDCService) getKey(d *oidcDiscovery, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	resp, err := s.Client.Get(d.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range jwks.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	s.keys = keys
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// Providers with a single key often omit kid from the token header.
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
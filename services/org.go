@@ -0,0 +1,213 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"gorm.io/gorm"
+)
+
+var orgNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+type OrgService struct {
+	DB *gorm.DB
+}
+
+func NewOrgService(db *gorm.DB) *OrgService {
+	return &OrgService{DB: db}
+}
+
+// OrgMembership is an organization as seen by one of its members.
+type OrgMembership struct {
+	models.Organization
+	Role string
+}
+
+// CreateOrg creates an organization with uid as its first owner.
+func (s *OrgService) CreateOrg(uid uint64, name string) (*models.Organization, error) {
+	if !orgNamePattern.MatchString(name) {
+		return nil, errors.New("invalid organization name")
+	}
+	if org, _ := s.FindOrgByName(name); org != nil {
+		return nil, errors.New(name + " exists")
+	}
+
+	org := models.Organization{Name: name, CreatedBy: uid}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrgMember{OrgID: org.ID, UID: uid, Role: models.OrgRoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (s *OrgService) FindOrgByName(name string) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.DB.Where("name = ?", name).First(&org).Error; err != nil {
+		return nil, errors.New("Organization " + name + " not exists or permission denied")
+	}
+	return &org, nil
+}
+
+// MemberRole returns uid's role in orgID, or "" if uid is not a member.
+func (s *OrgService) MemberRole(orgID uint, uid uint64) string {
+	var member models.OrgMember
+	if err := s.DB.Where("org_id = ? AND uid = ?", orgID, uid).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// MemberCan finds orgName and checks that uid holds one of roles in it.
+// Without roles any membership is enough.
+func (s *OrgService) MemberCan(uid uint64, orgName string, roles ...string) (*models.Organization, string, error) {
+	org, err := s.FindOrgByName(orgName)
+	if err != nil {
+		return nil, "", err
+	}
+	role := s.MemberRole(org.ID, uid)
+	if role == "" {
+		return nil, "", errors.New("Organization " + orgName + " not exists or permission denied")
+	}
+	if len(roles) == 0 {
+		return org, role, nil
+	}
+	for _, r := range roles {
+		if r == role {
+			return org, role, nil
+		}
+	}
+	return nil, "", errors.New("permission denied, you are not an organization " + roles[0])
+}
+
+func (s *OrgService) ListOrgs(uid uint64) ([]OrgMembership, error) {
+	var members []models.OrgMember
+	if err := s.DB.Where("uid = ?", uid).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[uint]string, len(members))
+	ids := make([]uint, len(members))
+	for i, m := range members {
+		roles[m.OrgID] = m.Role
+		ids[i] = m.OrgID
+	}
+	var orgs []models.Organization
+	if err := s.DB.Where("id IN ?", ids).Order("name ASC").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	result := make([]OrgMembership, len(orgs))
+	for i, org := range orgs {
+		result[i] = OrgMembership{Organization: org, Role: roles[org.ID]}
+	}
+	return result, nil
+}
+
+func (s *OrgService) Members(orgID uint) ([]models.OrgMember, error) {
+	var members []models.OrgMember
+	if err := s.DB.Where("org_id = ?", orgID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *OrgService) Apps(orgID uint) ([]models.App, error) {
+	var apps []models.App
+	if err := s.DB.Where("org_id = ?", orgID).Order("name ASC").Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// SetMember adds uid to the organization or changes their role.
+func (s *OrgService) SetMember(orgID uint, uid uint64, role string) error {
+	if role != models.OrgRoleOwner && role != models.OrgRoleAdmin && role != models.OrgRoleMember {
+		return errors.New("invalid role " + role)
+	}
+	var member models.OrgMember
+	err := s.DB.Where("org_id = ? AND uid = ?", orgID, uid).First(&member).Error
+	if err == gorm.ErrRecordNotFound {
+		return s.DB.Create(&models.OrgMember{OrgID: orgID, UID: uid, Role: role}).Error
+	} else if err != nil {
+		return err
+	}
+	if member.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(orgID); err != nil {
+			return err
+		}
+	}
+	return s.DB.Model(&member).Update("role", role).Error
+}
+
+func (s *OrgService) RemoveMember(orgID uint, uid uint64) error {
+	if s.MemberRole(orgID, uid) == models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(orgID); err != nil {
+			return err
+		}
+	}
+	res := s.DB.Where("org_id = ? AND uid = ?", orgID, uid).Delete(&models.OrgMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not a member of this organization")
+	}
+	return nil
+}
+
+// DeleteOrg removes an organization that no longer owns any apps.
+func (s *OrgService) DeleteOrg(orgID uint) error {
+	var apps int64
+	if err := s.DB.Model(&models.App{}).Where("org_id = ?", orgID).Count(&apps).Error; err != nil {
+		return err
+	}
+	if apps > 0 {
+		return errors.New("organization still owns apps, delete or transfer them first")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", orgID).Delete(&models.OrgMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, orgID).Error
+	})
+}
+
+// Names maps organization IDs to names, for displaying "org/app".
+func (s *OrgService) Names(ids []uint) (map[uint]string, error) {
+	var orgs []models.Organization
+	if err := s.DB.Unscoped().Where("id IN ?", ids).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(orgs))
+	for _, org := range orgs {
+		names[org.ID] = org.Name
+	}
+	return names, nil
+}
+
+func (s *OrgService) checkNotLastOwner(orgID uint) error {
+	var owners int64
+	if err := s.DB.Model(&models.OrgMember{}).Where("org_id = ? AND role = ?", orgID, models.OrgRoleOwner).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.New("an organization needs at least one owner")
+	}
+	return nil
+}
+
+// AppRoleForOrgRole is the collaborator role org members inherit on org apps.
+func AppRoleForOrgRole(role string) string {
+	switch role {
+	case models.OrgRoleOwner, models.OrgRoleAdmin:
+		return "Owner"
+	case models.OrgRoleMember:
+		return "Collaborator"
+	}
+	return ""
+}
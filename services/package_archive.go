@@ -0,0 +1,296 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/utils"
+)
+
+const maxPackageFiles = 20000
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported package: upload a zip, a tar.gz or the files of a directory")
+	ErrEmptyPackage       = errors.New("package contains no files")
+	ErrPackageTooLarge    = errors.New("package exceeds the maximum size")
+)
+
+// packageEpoch is the modification time of every entry of a normalized
+// package, so the same files always produce the same zip.
+var packageEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// PackageStager collects the files of an upload in a temporary directory
+// before they are zipped into the canonical package layout: regular files
+// only, with slash separated paths relative to the package root.
+type PackageStager struct {
+	Dir   string
+	files int
+	size  int64
+	limit int64
+}
+
+func NewPackageStager() (*PackageStager, error) {
+	dir, err := os.MkdirTemp(utils.Config.Common.TempDir, "package-")
+	if err != nil {
+		return nil, err
+	}
+	return &PackageStager{Dir: dir, limit: int64(utils.Config.Common.MaxPackageSizeMB) << 20}, nil
+}
+
+// Close removes the staged files.
+func (s *PackageStager) Close() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// AddFile stores one file of the package. OS metadata files such as
+// .DS_Store and __MACOSX are dropped.
+func (s *PackageStager) AddFile(name string, r io.Reader) error {
+	rel, err := cleanPackagePath(name)
+	if err != nil {
+		return err
+	}
+	if isPackageJunk(rel) {
+		return nil
+	}
+	if s.files++; s.files > maxPackageFiles {
+		return fmt.Errorf("package has more than %d files", maxPackageFiles)
+	}
+
+	dst := filepath.Join(s.Dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return errors.New("duplicate file " + rel)
+		}
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, s.limit-s.size+1))
+	s.size += n
+	if err != nil {
+		return err
+	}
+	if s.size > s.limit {
+		return ErrPackageTooLarge
+	}
+	return nil
+}
+
+// AddArchive unpacks a zip or tar.gz, recognized by its content rather than
+// its name. Reading every zip entry to the end verifies its checksum.
+func (s *PackageStager) AddArchive(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return ErrUnsupportedArchive
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		return s.addZip(f)
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		return s.addTarGz(f)
+	default:
+		return ErrUnsupportedArchive
+	}
+}
+
+func (s *PackageStager) addZip(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("invalid zip: %v", err)
+	}
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			return errors.New("unsupported zip entry " + entry.Name)
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("invalid zip entry %s: %v", entry.Name, err)
+		}
+		err = s.AddFile(entry.Name, rc)
+		rc.Close()
+		if err == zip.ErrChecksum || err == zip.ErrFormat || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("corrupt zip entry %s: %v", entry.Name, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsZip reports whether r starts like a zip archive.
+func IsZip(r io.ReaderAt) bool {
+	magic := make([]byte, 4)
+	_, err := r.ReadAt(magic, 0)
+	return err == nil && bytes.Equal(magic, []byte("PK\x03\x04"))
+}
+
+// CheckPackageZip checks a zip that is released as uploaded, from its
+// central directory alone: regular files inside the package root, without
+// duplicates, and no more files or uncompressed bytes than a normalized
+// package may have. Entry data is not read.
+func CheckPackageZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip: %v", err)
+	}
+	limit := uint64(utils.Config.Common.MaxPackageSizeMB) << 20
+	seen := make(map[string]bool)
+	var total uint64
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			return errors.New("unsupported zip entry " + entry.Name)
+		}
+		rel, err := cleanPackagePath(entry.Name)
+		if err != nil {
+			return err
+		}
+		if seen[rel] {
+			return errors.New("duplicate file " + rel)
+		}
+		seen[rel] = true
+		if len(seen) > maxPackageFiles {
+			return fmt.Errorf("package has more than %d files", maxPackageFiles)
+		}
+		if total += entry.UncompressedSize64; total > limit {
+			return ErrPackageTooLarge
+		}
+	}
+	if len(seen) == 0 {
+		return ErrEmptyPackage
+	}
+	return nil
+}
+
+func (s *PackageStager) addTarGz(f *os.File) error {
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("invalid tar.gz: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar.gz: %v", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+			if err := s.AddFile(hdr.Name, tr); err != nil {
+				if err == io.ErrUnexpectedEOF {
+					return fmt.Errorf("truncated tar.gz at %s", hdr.Name)
+				}
+				return err
+			}
+		default:
+			return errors.New("unsupported tar entry " + hdr.Name)
+		}
+	}
+}
+
+// WriteZip zips the staged files into dst in sorted order.
+func (s *PackageStager) WriteZip(dst string) error {
+	var names []string
+	err := filepath.WalkDir(s.Dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return ErrEmptyPackage
+	}
+	sort.Strings(names)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	zw := zip.NewWriter(out)
+	for _, name := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: packageEpoch})
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(filepath.Join(s.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// cleanPackagePath rejects paths that would land outside the package root.
+func cleanPackagePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\x00") || filepath.VolumeName(name) != "" {
+		return "", errors.New("invalid path in package: " + name)
+	}
+	rel := path.Clean(name)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || strings.Contains(rel, ":") {
+		return "", errors.New("invalid path in package: " + name)
+	}
+	return rel, nil
+}
+
+func isPackageJunk(rel string) bool {
+	return path.Base(rel) == ".DS_Store" || rel == "__MACOSX" || strings.HasPrefix(rel, "__MACOSX/")
+}
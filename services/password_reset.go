@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// passwordResetCooldown limits how often reset mails go out for one account.
+const passwordResetCooldown = time.Minute
+
+type PasswordResetService struct {
+	DB         *gorm.DB
+	Mailer     utils.Mailer
+	SessionSvc *SessionService
+}
+
+func NewPasswordResetService(db *gorm.DB, mailer utils.Mailer, sessionSvc *SessionService) *PasswordResetService {
+	return &PasswordResetService{DB: db, Mailer: mailer, SessionSvc: sessionSvc}
+}
+
+// Request mails a reset link to email. It reports success for unknown
+// addresses too, so the endpoint cannot be used to probe for accounts.
+func (s *PasswordResetService) Request(email string) error {
+	var user models.User
+	if err := s.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		log.Printf("Password reset requested for %s account %s, ignoring", user.AuthSource, email)
+		return nil
+	}
+	return s.Send(&user)
+}
+
+// Send issues a fresh reset link for user and mails it.
+func (s *PasswordResetService) Send(user *models.User) error {
+	var recent int64
+	s.DB.Model(&models.PasswordReset{}).
+		Where("uid = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		return nil
+	}
+	return s.send(user)
+}
+
+// Force invalidates the user's current password and sessions, then mails a
+// reset link. Used by admins when an account may be compromised.
+func (s *PasswordResetService) Force(user *models.User) error {
+	if err := s.DB.Model(user).Updates(map[string]interface{}{
+		"password": utils.HashPassword(utils.RandSecret(32)),
+		"ack_code": utils.RandToken(5),
+	}).Error; err != nil {
+		return err
+	}
+	if err := s.SessionSvc.RevokeAll(user.ID); err != nil {
+		return err
+	}
+	return s.send(user)
+}
+
+func (s *PasswordResetService) send(user *models.User) error {
+	token := utils.RandSecret(32)
+	ttl := utils.Config.Common.PasswordResetTTL
+	if err := s.DB.Create(&models.PasswordReset{
+		UID:       user.ID,
+		TokenHash: utils.Sha256(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error; err != nil {
+		return err
+	}
+
+	link := utils.Config.Common.PublicURL + "/auth/password/reset?token=" + url.QueryEscape(token)
+	body := "A password reset was requested for your CodePushServer account " + user.Email + ".\n\n" +
+		"Open this link to choose a new password:\n" + link + "\n\n" +
+		"The link expires in " + ttl.String() + " and can be used once. If you did not request it, ignore this mail."
+	return s.Mailer.Send(user.Email, "Reset your CodePushServer password", body)
+}
+
+// Reset consumes token and sets a new password. Rotating the AckCode and
+// revoking sessions signs the account out everywhere.
+func (s *PasswordResetService) Reset(token, newPassword string) (*models.User, error) {
+	var reset models.PasswordReset
+	if err := s.DB.Where("token_hash = ? AND expires_at > ?", utils.Sha256(token), time.Now()).
+		First(&reset).Error; err != nil {
+		return nil, errors.New("reset link is invalid or has expired")
+	}
+
+	var user models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Consuming every outstanding link of the user makes this one single-use.
+		res := tx.Where("uid = ?", reset.UID).Delete(&models.PasswordReset{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("reset link is invalid or has expired")
+		}
+		if err := tx.First(&user, reset.UID).Error; err != nil {
+			return err
+		}
+		user.Password = utils.HashPassword(newPassword)
+		user.AckCode = utils.RandToken(5)
+		return tx.Save(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.SessionSvc.RevokeAll(user.ID); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
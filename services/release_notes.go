@@ -0,0 +1,156 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxReleaseNotes       = 50
+	maxReleaseNoteBodyLen = 10000
+)
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// ReleaseNote is how clients send and receive a localized release note.
+type ReleaseNote struct {
+	Locale string `json:"locale"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Format string `json:"format"`
+}
+
+// ParseReleaseNotes reads the releaseNotes field of a release, a JSON list
+// of notes with at most one note per locale.
+func ParseReleaseNotes(raw string) ([]ReleaseNote, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var notes []ReleaseNote
+	if err := json.Unmarshal([]byte(raw), &notes); err != nil {
+		return nil, errors.New("releaseNotes must be a JSON list of {locale, title, body, format}")
+	}
+	if len(notes) > maxReleaseNotes {
+		return nil, errors.New("too many release notes")
+	}
+	seen := make(map[string]bool)
+	for i := range notes {
+		note := &notes[i]
+		note.Locale = NormalizeLocale(note.Locale)
+		if !localePattern.MatchString(note.Locale) {
+			return nil, errors.New("invalid release note locale " + notes[i].Locale)
+		}
+		if seen[note.Locale] {
+			return nil, errors.New("duplicate release note locale " + note.Locale)
+		}
+		seen[note.Locale] = true
+
+		note.Format = strings.ToLower(strings.TrimSpace(note.Format))
+		switch note.Format {
+		case "":
+			note.Format = models.ReleaseNoteFormatText
+		case models.ReleaseNoteFormatText, models.ReleaseNoteFormatMarkdown:
+		default:
+			return nil, errors.New("release note format must be text or markdown")
+		}
+		if note.Body == "" || len(note.Body) > maxReleaseNoteBodyLen {
+			return nil, errors.New("release note body of " + note.Locale + " is empty or too long")
+		}
+	}
+	return notes, nil
+}
+
+// NormalizeLocale lowercases a locale and accepts "_" as separator, so
+// "pt_BR" and "pt-br" are the same locale.
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// SaveReleaseNotes replaces the release notes of a package.
+func (s *AppService) SaveReleaseNotes(packageID uint, notes []ReleaseNote) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return saveReleaseNotes(tx, packageID, notes)
+	})
+}
+
+// saveReleaseNotes replaces the release notes of a package within tx, so
+// they are stored together with the package or not at all.
+func saveReleaseNotes(tx *gorm.DB, packageID uint, notes []ReleaseNote) error {
+	if err := tx.Where("package_id = ?", packageID).Delete(&models.PackageReleaseNote{}).Error; err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	rows := make([]models.PackageReleaseNote, len(notes))
+	for i, note := range notes {
+		rows[i] = models.PackageReleaseNote{
+			PackageID: packageID,
+			Locale:    note.Locale,
+			Title:     note.Title,
+			Body:      note.Body,
+			Format:    note.Format,
+		}
+	}
+	return tx.Create(&rows).Error
+}
+
+func (s *AppService) ReleaseNotes(packageID uint) ([]ReleaseNote, error) {
+	return releaseNotes(s.DB, packageID)
+}
+
+func releaseNotes(db *gorm.DB, packageID uint) ([]ReleaseNote, error) {
+	var rows []models.PackageReleaseNote
+	if err := db.Where("package_id = ?", packageID).Order("locale ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	notes := make([]ReleaseNote, len(rows))
+	for i, row := range rows {
+		notes[i] = ReleaseNote{Locale: row.Locale, Title: row.Title, Body: row.Body, Format: row.Format}
+	}
+	return notes, nil
+}
+
+// CopyReleaseNotes gives a promoted or rolled back package the notes of
+// the package it was copied from, within the transaction creating it.
+func CopyReleaseNotes(tx *gorm.DB, fromPackageID, toPackageID uint) error {
+	notes, err := releaseNotes(tx, fromPackageID)
+	if err != nil {
+		return err
+	}
+	return saveReleaseNotes(tx, toPackageID, notes)
+}
+
+// releaseNoteFor picks the note of packageID for locale: the exact locale,
+// then its language ("pt" for "pt-br"), then another region of that
+// language. Nil means the client gets the default description.
+func (s *ClientService) releaseNoteFor(packageID uint, locale string) *models.PackageReleaseNote {
+	locale = NormalizeLocale(locale)
+	if locale == "" {
+		return nil
+	}
+	language := strings.SplitN(locale, "-", 2)[0]
+
+	var rows []models.PackageReleaseNote
+	if err := s.DB.Where("package_id = ? AND (locale = ? OR locale LIKE ?)", packageID, language, language+"-%").
+		Order("locale ASC").Find(&rows).Error; err != nil || len(rows) == 0 {
+		return nil
+	}
+	best := -1
+	for i, row := range rows {
+		switch {
+		case row.Locale == locale:
+			return &rows[i]
+		case row.Locale == language:
+			best = i
+		case best == -1:
+			best = i
+		}
+	}
+	return &rows[best]
+}
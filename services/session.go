@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// ErrAccountDisabled is returned when an admin has disabled the account.
+var ErrAccountDisabled = errors.New("account has been disabled")
+
+type SessionService struct {
+	DB *gorm.DB
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{DB: db}
+}
+
+// TokenPair is what a successful login or refresh hands back to the dashboard.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token lifetime in seconds
+}
+
+// Create opens a new session for user and issues its first token pair.
+func (s *SessionService) Create(user *models.User, ip, userAgent string) (*TokenPair, error) {
+	if user.IsDisabled == 1 {
+		return nil, ErrAccountDisabled
+	}
+	refreshToken := utils.RandSecret(32)
+	now := time.Now()
+	session := models.UserSession{
+		UID:          user.ID,
+		RefreshToken: utils.Sha256(refreshToken),
+		AckHash:      utils.Md5(user.AckCode),
+		IP:           ip,
+		UserAgent:    userAgent,
+		ExpiresAt:    now.Add(utils.Config.JWT.RefreshTokenTTL),
+		LastUsedAt:   now,
+	}
+	if err := s.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return s.issue(user, &session, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The refresh token
+// is rotated, so each one can only be used once.
+func (s *SessionService) Refresh(refreshToken, ip, userAgent string) (*TokenPair, error) {
+	var session models.UserSession
+	if err := s.DB.Where("refresh_token = ? AND expires_at > ?", utils.Sha256(refreshToken), time.Now()).
+		First(&session).Error; err != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, session.UID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.IsDisabled == 1 {
+		return nil, ErrAccountDisabled
+	}
+	if utils.Md5(user.AckCode) != session.AckHash {
+		s.DB.Delete(&session)
+		return nil, errors.New("session has been revoked")
+	}
+
+	// Rotate only if the token is still the one we read, so two refreshes
+	// racing with the same token cannot both get a new one.
+	newRefreshToken := utils.RandSecret(32)
+	oldHash := session.RefreshToken
+	session.RefreshToken = utils.Sha256(newRefreshToken)
+	session.IP = ip
+	session.UserAgent = userAgent
+	session.LastUsedAt = time.Now()
+	result := s.DB.Model(&session).Where("refresh_token = ?", oldHash).
+		Select("refresh_token", "ip", "user_agent", "last_used_at").Updates(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+	return s.issue(&user, &session, newRefreshToken)
+}
+
+// Revoke ends a single session.
+func (s *SessionService) Revoke(uid, sessionID uint64) error {
+	return s.DB.Where("id = ? AND uid = ?", sessionID, uid).Delete(&models.UserSession{}).Error
+}
+
+// RevokeAll ends every session of uid ("sign out everywhere").
+func (s *SessionService) RevokeAll(uid uint64) error {
+	return s.DB.Where("uid = ?", uid).Delete(&models.UserSession{}).Error
+}
+
+func (s *SessionService) issue(user *models.User, session *models.UserSession, refreshToken string) (*TokenPair, error) {
+	now := time.Now()
+	ttl := utils.Config.JWT.AccessTokenTTL
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":  user.ID,
+		"hash": utils.Md5(user.AckCode),
+		"jti":  strconv.FormatUint(session.ID, 10),
+		"iat":  now.Unix(),
+		"exp":  now.Add(ttl).Unix(),
+	}).SignedString([]byte(utils.Config.JWT.TokenSecret))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl.Seconds()),
+	}, nil
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// blobColumns are the columns holding storage keys, with the URL columns
+// older rows have instead.
+var blobColumns = []struct {
+	model    interface{}
+	key, url string
+}{
+	{&models.Package{}, "blob_key", "blob_url"},
+	{&models.Package{}, "manifest_blob_key", "manifest_blob_url"},
+	{&models.PackageDiff{}, "diff_blob_key", "diff_blob_url"},
+}
+
+// StorageMigration copies the objects packages and diffs refer to from one
+// storage backend to another. Objects already copied are skipped, so an
+// interrupted run can be repeated.
+type StorageMigration struct {
+	DB   *gorm.DB
+	From utils.Storage
+	To   utils.Storage
+	// Progress, when set, is told about every key or URL handled.
+	Progress func(object, result string)
+}
+
+// StorageMigrationResult counts the objects of a migration run.
+type StorageMigrationResult struct {
+	Copied, Skipped, Missing, Foreign int
+	Bytes                             int64
+}
+
+func (m *StorageMigration) Run(ctx context.Context) (*StorageMigrationResult, error) {
+	keys, err := distinctBlobValues(m.DB, true)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := distinctBlobValues(m.DB, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StorageMigrationResult{}
+	for _, key := range keys {
+		if err := m.migrate(ctx, key, result); err != nil {
+			return result, err
+		}
+	}
+	// Rows from before keys were stored get their key as they are copied.
+	for _, url := range urls {
+		key := utils.KeyFromURL(m.From, url)
+		if key == "" {
+			result.Foreign++
+			m.progress(url, "not in the source storage, left alone")
+			continue
+		}
+		if err := m.migrate(ctx, key, result); err != nil {
+			return result, err
+		}
+		if err := setBlobKey(m.DB, url, key); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (m *StorageMigration) migrate(ctx context.Context, key string, result *StorageMigrationResult) error {
+	size, copied, err := m.copy(ctx, key)
+	if errors.Is(err, utils.ErrObjectNotFound) {
+		result.Missing++
+		m.progress(key, "missing from both storages")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", key, err)
+	}
+	if copied {
+		result.Copied++
+		result.Bytes += size
+		m.progress(key, "copied")
+	} else {
+		result.Skipped++
+		m.progress(key, "already copied")
+	}
+	return nil
+}
+
+// copy puts the object under key into To unless a copy of the same size and
+// checksum is there already. The source is hashed while it is copied, and
+// the copy is checked against that hash, or its size where To does not keep
+// a SHA-256.
+func (m *StorageMigration) copy(ctx context.Context, key string) (int64, bool, error) {
+	src, err := m.From.Stat(ctx, key)
+	if errors.Is(err, utils.ErrObjectNotFound) {
+		// Released after the server switched to To.
+		if _, err := m.To.Stat(ctx, key); err == nil {
+			return 0, false, nil
+		}
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if dst, err := m.To.Stat(ctx, key); err == nil && sameObject(src, dst) {
+		return src.Size, false, nil
+	} else if err != nil && !errors.Is(err, utils.ErrObjectNotFound) {
+		return 0, false, err
+	}
+
+	r, err := m.From.Get(ctx, key)
+	if err != nil {
+		return 0, false, err
+	}
+	defer r.Close()
+	hash := sha256.New()
+	if err := m.To.Put(ctx, key, io.TeeReader(r, hash), src.Size); err != nil {
+		return 0, false, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if src.SHA256 != "" && sum != src.SHA256 {
+		return 0, false, fmt.Errorf("checksum mismatch while copying: read %s, expected %s", sum, src.SHA256)
+	}
+
+	dst, err := m.To.Stat(ctx, key)
+	if err != nil {
+		return 0, false, err
+	}
+	if !sameObject(utils.ObjectInfo{Size: src.Size, SHA256: sum}, dst) {
+		return 0, false, fmt.Errorf("copy differs from the source: %d bytes, SHA-256 %q, expected %d bytes, %s", dst.Size, dst.SHA256, src.Size, sum)
+	}
+	return src.Size, true, nil
+}
+
+// sameObject compares objects by size, and by SHA-256 where both storages
+// keep one.
+func sameObject(a, b utils.ObjectInfo) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	return a.SHA256 == "" || b.SHA256 == "" || a.SHA256 == b.SHA256
+}
+
+func (m *StorageMigration) progress(object, result string) {
+	if m.Progress != nil {
+		m.Progress(object, result)
+	}
+}
+
+// ConvertBlobURLs stores the key of the packages and diffs that only have a
+// URL. URLs of storage as configured are recognized, and so are URLs below
+// one of bases, for URLs built from an earlier download URL config. It
+// returns how many distinct URLs were converted and how many were not.
+func ConvertBlobURLs(db *gorm.DB, storage utils.Storage, bases []string) (int, int, error) {
+	urls, err := distinctBlobValues(db, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	var converted, unknown int
+	for _, url := range urls {
+		key := utils.KeyFromURL(storage, url)
+		for _, base := range bases {
+			if rest, ok := strings.CutPrefix(url, strings.TrimSuffix(base, "/")+"/"); key == "" && ok {
+				key = rest
+			}
+		}
+		if key == "" {
+			unknown++
+			continue
+		}
+		if err := setBlobKey(db, url, key); err != nil {
+			return converted, unknown, err
+		}
+		converted++
+	}
+	return converted, unknown, nil
+}
+
+// distinctBlobValues lists the distinct keys, or the URLs of rows without a
+// key, of all packages and diffs, deleted ones included.
+func distinctBlobValues(db *gorm.DB, keys bool) ([]string, error) {
+	seen := map[string]bool{}
+	var values []string
+	for _, col := range blobColumns {
+		query := db.Unscoped().Model(col.model)
+		column := col.key
+		if !keys {
+			// Key columns added to existing tables are NULL.
+			query = query.Where("COALESCE(" + col.key + ", '') = ''")
+			column = col.url
+		}
+		var found []string
+		if err := query.Distinct(column).Where(column+" <> ''").Pluck(column, &found).Error; err != nil {
+			return nil, err
+		}
+		for _, v := range found {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// setBlobKey replaces url with key on every row that has it.
+func setBlobKey(db *gorm.DB, url, key string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, col := range blobColumns {
+			if err := tx.Unscoped().Model(col.model).Where(col.url+" = ?", url).
+				Updates(map[string]interface{}{col.key: key, col.url: ""}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
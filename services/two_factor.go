@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "CodePushServer"
+	recoveryCodeCount = 10
+)
+
+type TwoFactorService struct {
+	DB *gorm.DB
+}
+
+func NewTwoFactorService(db *gorm.DB) *TwoFactorService {
+	return &TwoFactorService{DB: db}
+}
+
+// Enroll generates a new, not yet active secret for the user and returns it
+// with its provisioning URI. It only takes effect once Confirm succeeds.
+func (s *TwoFactorService) Enroll(user *models.User) (string, string, error) {
+	if user.TOTPEnabled == 1 {
+		return "", "", errors.New("two-factor authentication is already enabled")
+	}
+	secret := utils.GenerateTOTPSecret()
+	if err := s.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPProvisioningURI(secret, totpIssuer, user.Email), nil
+}
+
+// Confirm activates a pending enrollment and returns fresh recovery codes.
+func (s *TwoFactorService) Confirm(uid uint64, code string) ([]string, error) {
+	var user models.User
+	if err := s.DB.First(&user, uid).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabled == 1 {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("no pending two-factor enrollment")
+	}
+	step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": 1, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, uid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and issues new ones.
+func (s *TwoFactorService) RegenerateRecoveryCodes(uid uint64) ([]string, error) {
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, uid)
+		return err
+	})
+	return codes, err
+}
+
+// Disable turns 2FA off and drops the secret and recovery codes.
+func (s *TwoFactorService) Disable(uid uint64) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", uid).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled": 0, "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("uid = ?", uid).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// Verify checks a TOTP code, or failing that a recovery code, for an enrolled
+// user. Accepted TOTP steps and recovery codes cannot be used again.
+func (s *TwoFactorService) Verify(user *models.User, code string) (bool, error) {
+	if step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now()); ok {
+		if step <= user.TOTPLastStep {
+			return false, nil
+		}
+		res := s.DB.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		return res.RowsAffected == 1, nil
+	}
+
+	res := s.DB.Where("uid = ? AND code_hash = ?", user.ID, utils.Sha256(normalizeRecoveryCode(code))).
+		Delete(&models.RecoveryCode{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, uid uint64) ([]string, error) {
+	if err := tx.Unscoped().Where("uid = ?", uid).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := utils.RandSecret(5)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{UID: uid, CodeHash: utils.Sha256(raw)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
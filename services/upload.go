@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUploadNotFound      = errors.New("upload session not found")
+	ErrUploadOffset        = errors.New("chunk does not start at the current offset")
+	ErrUploadChecksum      = errors.New("chunk checksum mismatch")
+	ErrUploadIncomplete    = errors.New("upload is incomplete")
+	ErrUploadChunkTooLarge = errors.New("chunk is too large")
+	ErrDirectUpload        = errors.New("direct uploads go to the upload URL")
+	ErrNoDirectUpload      = errors.New("the configured storage does not support direct uploads")
+)
+
+// maxPresignTTL is the longest S3 accepts for a presigned URL.
+const maxPresignTTL = 7 * 24 * time.Hour
+
+// uploadLocks serializes the chunks of one session within this process.
+var uploadLocks sync.Map
+
+// UploadService keeps resumable upload sessions. Their files live under
+// TempDir/uploads, named after the session.
+type UploadService struct {
+	DB      *gorm.DB
+	Storage utils.Storage
+	Blobs   *BlobService
+}
+
+func NewUploadService(db *gorm.DB) *UploadService {
+	blobs := NewBlobService(db)
+	return &UploadService{DB: db, Storage: blobs.Storage, Blobs: blobs}
+}
+
+// Create opens a session for a file of size bytes; checksum is the optional
+// hex SHA-256 of the whole file.
+func (s *UploadService) Create(uid uint64, appID, deploymentID uint, fileName string, size int64, checksum string) (*models.UploadSession, error) {
+	limit := int64(utils.Config.Common.MaxPackageSizeMB) << 20
+	if size <= 0 || size > limit {
+		return nil, fmt.Errorf("size must be between 1 and %d bytes", limit)
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum != "" {
+		if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+			return nil, errors.New("sha256 must be a hex SHA-256 checksum")
+		}
+	}
+	if err := os.MkdirAll(uploadDir(), 0o755); err != nil {
+		return nil, err
+	}
+
+	session := models.UploadSession{
+		ID:           utils.RandSecret(16),
+		AppID:        appID,
+		DeploymentID: deploymentID,
+		UID:          uid,
+		FileName:     filepath.Base(fileName),
+		Size:         size,
+		SHA256:       checksum,
+		Status:       models.UploadSessionOpen,
+		ExpiresAt:    time.Now().Add(utils.Config.Common.UploadSessionTTL),
+	}
+	f, err := os.Create(UploadPath(&session))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := s.DB.Create(&session).Error; err != nil {
+		os.Remove(UploadPath(&session))
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreateDirect opens a session whose file the client PUTs to storage itself,
+// at the returned URL with the returned headers. checksum, the hex SHA-256
+// of the file, is required so the store can reject a corrupted upload.
+func (s *UploadService) CreateDirect(ctx context.Context, uid uint64, appID, deploymentID uint, fileName string, size int64, checksum string) (*models.UploadSession, string, http.Header, error) {
+	store, ok := s.Storage.(utils.DirectUploader)
+	if !ok {
+		return nil, "", nil, ErrNoDirectUpload
+	}
+	if checksum == "" {
+		return nil, "", nil, errors.New("sha256 is required for direct uploads")
+	}
+	limit := int64(utils.Config.Common.MaxPackageSizeMB) << 20
+	if size <= 0 || size > limit {
+		return nil, "", nil, fmt.Errorf("size must be between 1 and %d bytes", limit)
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+		return nil, "", nil, errors.New("sha256 must be a hex SHA-256 checksum")
+	}
+
+	id := utils.RandSecret(16)
+	session := models.UploadSession{
+		ID:           id,
+		AppID:        appID,
+		DeploymentID: deploymentID,
+		UID:          uid,
+		FileName:     filepath.Base(fileName),
+		Size:         size,
+		SHA256:       checksum,
+		StagingKey:   "staging/" + id,
+		Status:       models.UploadSessionOpen,
+		ExpiresAt:    time.Now().Add(utils.Config.Common.UploadSessionTTL),
+	}
+	ttl := utils.Config.Common.UploadSessionTTL
+	if ttl > maxPresignTTL {
+		ttl = maxPresignTTL
+	}
+	uploadURL, headers, err := store.PresignPut(ctx, session.StagingKey, size, checksum, ttl)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := s.DB.Create(&session).Error; err != nil {
+		return nil, "", nil, err
+	}
+	return &session, uploadURL, headers, nil
+}
+
+// Find returns an open, unexpired session of uid for the deployment.
+func (s *UploadService) Find(uid uint64, deploymentID uint, id string) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := s.DB.Where("id = ? AND uid = ? AND deployment_id = ? AND status = ? AND expires_at > ?",
+		id, uid, deploymentID, models.UploadSessionOpen, time.Now()).First(&session).Error
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+	return &session, nil
+}
+
+// WriteChunk stores the chunk starting at offset and returns the new
+// offset. Resending a chunk that was already stored is accepted, so clients
+// can retry when they lost the response. checksum is the optional hex
+// SHA-256 of the chunk.
+func (s *UploadService) WriteChunk(session *models.UploadSession, offset int64, r io.Reader, checksum string) (int64, error) {
+	if session.StagingKey != "" {
+		return 0, ErrDirectUpload
+	}
+	lock, _ := uploadLocks.LoadOrStore(session.ID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	// Another request may have moved the session on while we waited.
+	if err := s.DB.First(session, "id = ?", session.ID).Error; err != nil {
+		return 0, ErrUploadNotFound
+	}
+	if offset < session.Received {
+		return session.Received, nil
+	}
+	if offset != session.Received {
+		return session.Received, ErrUploadOffset
+	}
+
+	maxChunk := int64(utils.Config.Common.MaxUploadChunkMB) << 20
+	if rest := session.Size - offset; rest < maxChunk {
+		maxChunk = rest
+	}
+	f, err := os.OpenFile(UploadPath(session), os.O_WRONLY, 0)
+	if err != nil {
+		return session.Received, err
+	}
+	defer f.Close()
+	// Drop whatever a failed earlier attempt left past the acknowledged end.
+	if err := f.Truncate(offset); err != nil {
+		return session.Received, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return session.Received, err
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, maxChunk+1))
+	switch {
+	case err != nil:
+	case n > maxChunk:
+		err = ErrUploadChunkTooLarge
+	case checksum != "" && !strings.EqualFold(checksum, hex.EncodeToString(hash.Sum(nil))):
+		err = ErrUploadChecksum
+	}
+	if err != nil {
+		f.Truncate(offset)
+		return session.Received, err
+	}
+	if err := f.Sync(); err != nil {
+		return session.Received, err
+	}
+
+	// The process lock does not cover other nodes; only move on from the
+	// offset we checked.
+	res := s.DB.Model(&models.UploadSession{}).Where("id = ? AND received = ?", session.ID, offset).
+		Update("received", offset+n)
+	if res.Error != nil {
+		return offset, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return offset, ErrUploadOffset
+	}
+	session.Received = offset + n
+	return session.Received, nil
+}
+
+// Complete checks that the whole file arrived intact and marks the session
+// finalized. The caller owns the file afterwards.
+func (s *UploadService) Complete(session *models.UploadSession) error {
+	if session.Received != session.Size {
+		return ErrUploadIncomplete
+	}
+	if session.SHA256 != "" {
+		f, err := os.Open(UploadPath(session))
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != session.SHA256 {
+			return errors.New("upload checksum mismatch, start a new upload")
+		}
+	}
+	return s.finalize(session)
+}
+
+// CommitDirect verifies a direct upload and marks the session finalized.
+// When storage keeps the checksum of the upload, reads ranges and copies
+// objects, a zip is checked from its central directory and copied to its
+// blob within storage, without passing through the server, and the blob is
+// returned for the caller to release. Otherwise the upload is downloaded
+// to UploadPath, checked on the way, and the blob is nil: like after
+// Complete, the caller owns the file and releases it as any other upload.
+func (s *UploadService) CommitDirect(ctx context.Context, session *models.UploadSession) (*models.Blob, error) {
+	if _, ok := s.Storage.(utils.DirectUploader); !ok {
+		return nil, ErrNoDirectUpload
+	}
+	info, err := s.Storage.Stat(ctx, session.StagingKey)
+	if err != nil {
+		return nil, ErrUploadIncomplete
+	}
+	if info.Size != session.Size {
+		return nil, fmt.Errorf("uploaded %d bytes, expected %d", info.Size, session.Size)
+	}
+	if info.SHA256 != "" && info.SHA256 != session.SHA256 {
+		return nil, errors.New("upload checksum mismatch, start a new upload")
+	}
+
+	ranges, canRange := s.Storage.(utils.RangeReader)
+	_, canCopy := s.Storage.(utils.ObjectCopier)
+	if info.SHA256 != "" && canRange && canCopy {
+		r := utils.NewObjectReaderAt(ctx, ranges, session.StagingKey, session.Size)
+		// A tar.gz is normalized into a zip, which takes its content.
+		if IsZip(r) {
+			if err := CheckPackageZip(r, session.Size); err != nil {
+				return nil, err
+			}
+			blob, err := s.Blobs.Adopt(ctx, session.StagingKey, session.SHA256, session.Size)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.finalize(session); err != nil {
+				return nil, err
+			}
+			return blob, nil
+		}
+	}
+	return nil, s.download(ctx, session)
+}
+
+// download copies a direct upload to UploadPath, checking its size and
+// checksum, and marks the session finalized.
+func (s *UploadService) download(ctx context.Context, session *models.UploadSession) error {
+	r, err := s.Storage.Get(ctx, session.StagingKey)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := os.MkdirAll(uploadDir(), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(UploadPath(session))
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, session.Size+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != session.Size || hex.EncodeToString(hash.Sum(nil)) != session.SHA256 {
+		return errors.New("upload checksum mismatch, start a new upload")
+	}
+	return s.finalize(session)
+}
+
+func (s *UploadService) finalize(session *models.UploadSession) error {
+	res := s.DB.Model(&models.UploadSession{}).Where("id = ? AND status = ?", session.ID, models.UploadSessionOpen).
+		Update("status", models.UploadSessionFinalized)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUploadNotFound
+	}
+	uploadLocks.Delete(session.ID)
+	return nil
+}
+
+// Reopen makes a finalized session open again, for the client to retry
+// finalizing after the release failed.
+func (s *UploadService) Reopen(session *models.UploadSession) error {
+	return s.DB.Model(&models.UploadSession{}).Where("id = ? AND status = ?", session.ID, models.UploadSessionFinalized).
+		Update("status", models.UploadSessionOpen).Error
+}
+
+// Delete aborts a session and removes its file, and its staging object for
+// direct uploads.
+func (s *UploadService) Delete(session *models.UploadSession) error {
+	uploadLocks.Delete(session.ID)
+	if session.StagingKey != "" {
+		s.Storage.Delete(context.Background(), session.StagingKey)
+	}
+	os.Remove(UploadPath(session))
+	return s.DB.Delete(session).Error
+}
+
+// PurgeExpired removes expired sessions with their files.
+func (s *UploadService) PurgeExpired() {
+	var sessions []models.UploadSession
+	if err := s.DB.Where("expires_at <= ?", time.Now()).Find(&sessions).Error; err != nil {
+		log.Printf("Failed to load expired upload sessions: %v", err)
+		return
+	}
+	for i := range sessions {
+		if err := s.Delete(&sessions[i]); err != nil {
+			log.Printf("Failed to delete upload session %s: %v", sessions[i].ID, err)
+		}
+	}
+}
+
+// Run purges expired sessions until the process exits.
+func (s *UploadService) Run() {
+	for {
+		s.PurgeExpired()
+		time.Sleep(time.Hour)
+	}
+}
+
+// UploadPath is where the chunks of session are assembled.
+func UploadPath(session *models.UploadSession) string {
+	return filepath.Join(uploadDir(), session.ID+".part")
+}
+
+func uploadDir() string {
+	return filepath.Join(utils.Config.Common.TempDir, "uploads")
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+type EmailVerificationService struct {
+	DB     *gorm.DB
+	Mailer utils.Mailer
+}
+
+func NewEmailVerificationService(db *gorm.DB, mailer utils.Mailer) *EmailVerificationService {
+	return &EmailVerificationService{DB: db, Mailer: mailer}
+}
+
+// Send mails a fresh confirmation link to user, replacing any earlier one.
+func (s *EmailVerificationService) Send(user *models.User) error {
+	token := utils.RandSecret(32)
+	ttl := utils.Config.Common.EmailVerificationTTL
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uid = ?", user.ID).Delete(&models.EmailVerification{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.EmailVerification{
+			UID:       user.ID,
+			TokenHash: utils.Sha256(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := utils.Config.Common.PublicURL + "/auth/verify?token=" + url.QueryEscape(token)
+	body := "Welcome to CodePushServer.\n\n" +
+		"Open this link to confirm " + user.Email + " and activate your account:\n" + link + "\n\n" +
+		"The link expires in " + ttl.String() + "."
+	return s.Mailer.Send(user.Email, "Confirm your CodePushServer account", body)
+}
+
+// Resend mails a new link if email belongs to an unverified account. Like
+// password resets, it does not reveal whether the account exists.
+func (s *EmailVerificationService) Resend(email string) error {
+	var user models.User
+	if err := s.DB.Where("email = ? AND pending_verification = ?", email, 1).First(&user).Error; err != nil {
+		return nil
+	}
+	return s.Send(&user)
+}
+
+// Verify consumes token and activates the account it was issued for.
+func (s *EmailVerificationService) Verify(token string) (*models.User, error) {
+	var verification models.EmailVerification
+	if err := s.DB.Where("token_hash = ? AND expires_at > ?", utils.Sha256(token), time.Now()).
+		First(&verification).Error; err != nil {
+		return nil, errors.New("verification link is invalid or has expired")
+	}
+
+	var user models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&verification).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&user, verification.UID).Error; err != nil {
+			return err
+		}
+		user.PendingVerification = 0
+		return tx.Model(&user).Update("pending_verification", 0).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/utils"
+	"gorm.io/gorm"
+)
+
+// maxWebhookBackoff caps the delay between two delivery attempts.
+const maxWebhookBackoff = 6 * time.Hour
+
+// internalNetworks are the addresses webhooks may not be delivered to unless
+// WEBHOOK_ALLOWED_NETWORKS lists them, on top of loopback, private,
+// link-local and multicast ones: "this network" and shared address space.
+var internalNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+// WebhookService queues release lifecycle events for the webhooks of an app
+// and delivers them. Deliveries live in the database, so retries survive
+// restarts and any server instance can pick them up.
+type WebhookService struct {
+	DB     *gorm.DB
+	Client *http.Client
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	dialer := &net.Dialer{Timeout: utils.Config.Webhook.Timeout, Control: webhookDialControl(webhookAllowedNetworks())}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: utils.Config.Webhook.Timeout}
+	return &WebhookService{DB: db, Client: &http.Client{Timeout: utils.Config.Webhook.Timeout, Transport: transport}}
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	ID           string               `json:"id"`
+	Event        string               `json:"event"`
+	Timestamp    int64                `json:"timestamp"`
+	App          string               `json:"app"`
+	Deployment   string               `json:"deployment,omitempty"`
+	Actor        string               `json:"actor,omitempty"`
+	Package      *WebhookPackage      `json:"package,omitempty"`
+	Collaborator *WebhookCollaborator `json:"collaborator,omitempty"`
+}
+
+type WebhookPackage struct {
+	Label         string `json:"label"`
+	PackageHash   string `json:"packageHash"`
+	Description   string `json:"description"`
+	IsMandatory   bool   `json:"isMandatory"`
+	Rollout       uint8  `json:"rollout"`
+	Size          uint   `json:"size"`
+	ReleaseMethod string `json:"releaseMethod"`
+	ReleasedBy    string `json:"releasedBy"`
+}
+
+type WebhookCollaborator struct {
+	Email      string `json:"email"`
+	Permission string `json:"permission"`
+	Change     string `json:"change"` // "added" or "invited"
+}
+
+// NewWebhookPackage describes pkg for a payload; releasedBy is the releaser's email.
+func NewWebhookPackage(pkg *models.Package, releasedBy string) *WebhookPackage {
+	return &WebhookPackage{
+		Label:         pkg.Label,
+		PackageHash:   pkg.PackageHash,
+		Description:   pkg.Description,
+		IsMandatory:   pkg.IsMandatory == 1,
+		Rollout:       pkg.Rollout,
+		Size:          pkg.Size,
+		ReleaseMethod: pkg.ReleaseMethod,
+		ReleasedBy:    releasedBy,
+	}
+}
+
+// Create registers a webhook; deploymentID 0 subscribes it to the whole app.
+func (s *WebhookService) Create(appID, deploymentID uint, rawURL, format string, events []string, uid uint64) (*models.Webhook, error) {
+	if err := validateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	format, err := normalizeWebhookFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := normalizeWebhookEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	hook := models.Webhook{
+		AppID:        appID,
+		DeploymentID: deploymentID,
+		URL:          rawURL,
+		Secret:       utils.RandSecret(32),
+		Format:       format,
+		Events:       filter,
+		IsActive:     1,
+		CreatedBy:    uid,
+	}
+	if err := s.DB.Create(&hook).Error; err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// WebhookChanges lists the webhook fields to update; nil fields are kept.
+type WebhookChanges struct {
+	URL          *string
+	Format       *string
+	Events       []string
+	DeploymentID *uint
+	Active       *bool
+}
+
+func (s *WebhookService) Update(hook *models.Webhook, changes WebhookChanges) error {
+	fields := map[string]interface{}{}
+	if changes.URL != nil {
+		if err := validateWebhookURL(*changes.URL); err != nil {
+			return err
+		}
+		fields["url"] = *changes.URL
+	}
+	if changes.Format != nil {
+		format, err := normalizeWebhookFormat(*changes.Format)
+		if err != nil {
+			return err
+		}
+		fields["format"] = format
+	}
+	if changes.Events != nil {
+		filter, err := normalizeWebhookEvents(changes.Events)
+		if err != nil {
+			return err
+		}
+		fields["events"] = filter
+	}
+	if changes.DeploymentID != nil {
+		fields["deployment_id"] = *changes.DeploymentID
+	}
+	if changes.Active != nil {
+		fields["is_active"] = utils.BoolToUint8(*changes.Active)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.DB.Model(hook).Updates(fields).Error
+}
+
+func (s *WebhookService) Find(appID uint, id uint64) (*models.Webhook, error) {
+	var hook models.Webhook
+	if err := s.DB.Where("id = ? AND app_id = ?", id, appID).First(&hook).Error; err != nil {
+		return nil, errors.New("webhook not found")
+	}
+	return &hook, nil
+}
+
+func (s *WebhookService) List(appID uint) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	if err := s.DB.Where("app_id = ?", appID).Order("id ASC").Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (s *WebhookService) Delete(hook *models.Webhook) error {
+	return s.DB.Delete(hook).Error
+}
+
+// Deliveries pages through the delivery log of a webhook, newest first.
+func (s *WebhookService) Deliveries(webhookID uint64, page, pageSize int) ([]models.WebhookDelivery, int64, error) {
+	db := s.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// Emit queues payload for every active webhook of appID subscribed to its
+// event and deployment, and makes a first delivery attempt in the background.
+// Events without a deployment only reach webhooks of the whole app.
+func (s *WebhookService) Emit(appID uint, payload WebhookPayload) {
+	deploymentIDs := []uint{0}
+	if payload.Deployment != "" {
+		var deployment models.Deployment
+		if err := s.DB.Where("app_id = ? AND name = ?", appID, payload.Deployment).First(&deployment).Error; err == nil {
+			deploymentIDs = append(deploymentIDs, deployment.ID)
+		}
+	}
+	var hooks []models.Webhook
+	if err := s.DB.Where("app_id = ? AND is_active = ? AND deployment_id IN ?", appID, 1, deploymentIDs).
+		Find(&hooks).Error; err != nil {
+		log.Printf("Failed to load webhooks of app %d: %v", appID, err)
+		return
+	}
+	for i := range hooks {
+		if !webhookWants(&hooks[i], payload.Event) {
+			continue
+		}
+		delivery, err := s.enqueue(&hooks[i], payload)
+		if err != nil {
+			log.Printf("Failed to queue %s webhook %d: %v", payload.Event, hooks[i].ID, err)
+			continue
+		}
+		go s.deliver(delivery)
+	}
+}
+
+// Test sends a ping to hook right away and returns the resulting delivery.
+func (s *WebhookService) Test(hook *models.Webhook, appName, actor string) (*models.WebhookDelivery, error) {
+	payload := WebhookPayload{Event: models.WebhookEventPing, App: appName, Actor: actor}
+	if hook.DeploymentID != 0 {
+		var deployment models.Deployment
+		if err := s.DB.First(&deployment, hook.DeploymentID).Error; err == nil {
+			payload.Deployment = deployment.Name
+		}
+	}
+	delivery, err := s.enqueue(hook, payload)
+	if err != nil {
+		return nil, err
+	}
+	s.deliver(delivery)
+	if err := s.DB.First(delivery, delivery.ID).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Run delivers due deliveries until the process exits.
+func (s *WebhookService) Run() {
+	ticker := time.NewTicker(utils.Config.Webhook.PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var due []models.WebhookDelivery
+		if err := s.DB.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Order("next_attempt_at ASC").Limit(50).Find(&due).Error; err != nil {
+			log.Printf("Failed to load webhook deliveries: %v", err)
+			continue
+		}
+		for i := range due {
+			s.deliver(&due[i])
+		}
+	}
+}
+
+func (s *WebhookService) enqueue(hook *models.Webhook, payload WebhookPayload) (*models.WebhookDelivery, error) {
+	if payload.ID == "" {
+		payload.ID = utils.RandSecret(12)
+	}
+	if payload.Timestamp == 0 {
+		payload.Timestamp = time.Now().UnixMilli()
+	}
+	body, err := formatWebhookPayload(hook.Format, payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         payload.Event,
+		Payload:       string(body),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := s.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// deliver makes one attempt. Claiming the delivery first pushes its next
+// attempt out, so concurrent workers never send it twice at once.
+func (s *WebhookService) deliver(delivery *models.WebhookDelivery) {
+	cfg := utils.Config.Webhook
+	res := s.DB.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, models.WebhookDeliveryPending, time.Now()).
+		Update("next_attempt_at", time.Now().Add(cfg.Timeout+time.Minute))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	if err := s.DB.First(delivery, delivery.ID).Error; err != nil {
+		return
+	}
+
+	var hook models.Webhook
+	if err := s.DB.First(&hook, delivery.WebhookID).Error; err != nil {
+		s.DB.Model(delivery).Updates(map[string]interface{}{
+			"status": models.WebhookDeliveryFailed, "last_error": "webhook was deleted",
+		})
+		return
+	}
+
+	statusCode, sendErr := s.send(&hook, delivery)
+	delivery.Attempts++
+	fields := map[string]interface{}{"attempts": delivery.Attempts, "last_status_code": statusCode, "last_error": ""}
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		fields["status"] = models.WebhookDeliverySucceeded
+		fields["delivered_at"] = &now
+	case delivery.Attempts >= cfg.MaxAttempts:
+		fields["status"] = models.WebhookDeliveryFailed
+		fields["last_error"] = sendErr.Error()
+	default:
+		backoff := cfg.BaseBackoff << (delivery.Attempts - 1)
+		if backoff <= 0 || backoff > maxWebhookBackoff {
+			backoff = maxWebhookBackoff
+		}
+		fields["next_attempt_at"] = time.Now().Add(backoff)
+		fields["last_error"] = sendErr.Error()
+	}
+	if err := s.DB.Model(delivery).Updates(fields).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (s *WebhookService) send(hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CodePushServer-Webhook")
+	req.Header.Set("X-CodePush-Event", delivery.Event)
+	req.Header.Set("X-CodePush-Delivery", strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set("X-CodePush-Signature", "sha256="+SignWebhookPayload(hook.Secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The body is not kept: the delivery log is readable by app owners, and
+	// the receiver may not be theirs.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload is the hex HMAC-SHA256 of body, sent as
+// "X-CodePush-Signature: sha256=<signature>".
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookWants(hook *models.Webhook, event string) bool {
+	if hook.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(hook.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeWebhookEvents(events []string) (string, error) {
+	var filter []string
+	for _, e := range events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || e == "*" {
+			return "", nil
+		}
+		known := false
+		for _, k := range models.WebhookEvents {
+			if e == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", errors.New("unknown event " + e)
+		}
+		filter = append(filter, e)
+	}
+	return strings.Join(filter, ","), nil
+}
+
+func normalizeWebhookFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return models.WebhookFormatJSON, nil
+	}
+	for _, f := range models.WebhookFormats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", errors.New("unknown format " + format)
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be an absolute http(s) URL")
+	}
+	// Host names are checked once resolved, when a delivery connects.
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !webhookAddressAllowed(ip, webhookAllowedNetworks()) {
+		return errors.New("webhook URL must not point at an internal address")
+	}
+	return nil
+}
+
+// webhookDialControl refuses connections to internal addresses. It runs
+// after name resolution, for every address tried and every redirect, so a
+// host name cannot be pointed at an internal address later.
+func webhookDialControl(allowed []*net.IPNet) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !webhookAddressAllowed(ip, allowed) {
+			return fmt.Errorf("webhook address %s is not allowed", host)
+		}
+		return nil
+	}
+}
+
+func webhookAddressAllowed(ip net.IP, allowed []*net.IPNet) bool {
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookAllowedNetworks parses WEBHOOK_ALLOWED_NETWORKS, a comma separated
+// list of CIDRs or single addresses webhooks may be delivered to even
+// though they are internal.
+func webhookAllowedNetworks() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(utils.Config.Webhook.AllowedNetworks, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid WEBHOOK_ALLOWED_NETWORKS entry %q", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
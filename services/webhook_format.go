@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/venkatvghub/code-push-server-go/models"
+)
+
+// chatFact is one "name: value" line of a chat notification.
+type chatFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// formatWebhookPayload renders payload the way a webhook's format expects
+// it: the event itself for json, a readable summary for chat services.
+func formatWebhookPayload(format string, payload WebhookPayload) ([]byte, error) {
+	switch format {
+	case models.WebhookFormatSlack:
+		return json.Marshal(map[string]string{"text": chatText(payload, "*", slackEscape)})
+	case models.WebhookFormatMattermost:
+		return json.Marshal(map[string]string{"text": chatText(payload, "**", mattermostEscape)})
+	case models.WebhookFormatTeams:
+		title := chatTitle(payload)
+		return json.Marshal(map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"themeColor": "0076D7",
+			"title":      title,
+			"sections":   []map[string]interface{}{{"facts": chatFacts(payload)}},
+		})
+	default:
+		return json.Marshal(payload)
+	}
+}
+
+// chatText is the markdown used by Slack and Mattermost; bold is their
+// strong emphasis marker and escape, when set, quotes user supplied text.
+func chatText(payload WebhookPayload, bold string, escape func(string) string) string {
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	var b strings.Builder
+	b.WriteString(bold + escape(chatTitle(payload)) + bold)
+	for _, fact := range chatFacts(payload) {
+		b.WriteString("\n" + bold + fact.Name + ":" + bold + " " + escape(fact.Value))
+	}
+	return b.String()
+}
+
+func chatTitle(p WebhookPayload) string {
+	target := p.App
+	if p.Deployment != "" {
+		target += " / " + p.Deployment
+	}
+	label := ""
+	if p.Package != nil {
+		label = p.Package.Label
+	}
+	switch p.Event {
+	case models.WebhookEventRelease:
+		return fmt.Sprintf("%s released %s to %s", p.Actor, label, target)
+	case models.WebhookEventPromote:
+		return fmt.Sprintf("%s promoted %s to %s", p.Actor, label, target)
+	case models.WebhookEventRollback:
+		return fmt.Sprintf("%s rolled back %s (now %s)", p.Actor, target, label)
+	case models.WebhookEventCollaborator:
+		if p.Collaborator != nil {
+			return fmt.Sprintf("%s %s %s as %s of %s", p.Actor, p.Collaborator.Change, p.Collaborator.Email, p.Collaborator.Permission, p.App)
+		}
+	case models.WebhookEventPing:
+		return fmt.Sprintf("Test notification for %s from %s", p.App, p.Actor)
+	}
+	return fmt.Sprintf("%s event on %s", p.Event, target)
+}
+
+func chatFacts(p WebhookPayload) []chatFact {
+	facts := []chatFact{{"App", p.App}}
+	if p.Deployment != "" {
+		facts = append(facts, chatFact{"Deployment", p.Deployment})
+	}
+	if pkg := p.Package; pkg != nil {
+		mandatory := "No"
+		if pkg.IsMandatory {
+			mandatory = "Yes"
+		}
+		facts = append(facts, chatFact{"Label", pkg.Label})
+		if pkg.Description != "" {
+			facts = append(facts, chatFact{"Description", pkg.Description})
+		}
+		facts = append(facts,
+			chatFact{"Mandatory", mandatory},
+			chatFact{"Rollout", fmt.Sprintf("%d%%", pkg.Rollout)},
+			chatFact{"Released by", pkg.ReleasedBy},
+		)
+	}
+	return facts
+}
+
+// slackEscape quotes the characters Slack treats as markup.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+var mattermostReplacer = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`, "#", `\#`, "|", `\|`,
+	"[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "<", `\<`, ">", `\>`,
+	// A zero width space after the @ keeps names, @channel and @all from
+	// notifying anyone.
+	"@", "@\u200b",
+)
+
+// mattermostEscape quotes the characters Mattermost treats as markdown and
+// breaks @-mentions.
+func mattermostEscape(s string) string {
+	return mattermostReplacer.Replace(s)
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/venkatvghub/code-push-server-go/config"
 	"github.com/venkatvghub/code-push-server-go/models"
+	"github.com/venkatvghub/code-push-server-go/services"
+	"github.com/venkatvghub/code-push-server-go/utils"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -22,22 +26,7 @@ func main() {
 		Use:   "migrate",
 		Short: "Migrate the database schema",
 		Run: func(cmd *cobra.Command, args []string) {
-			cfg := config.LoadConfig()
-
-			dsn := fmt.Sprintf(
-				"host=%s port=%s user=%s dbname=%s sslmode=%s password=%s",
-				cfg.DB.Host,
-				cfg.DB.Port,
-				cfg.DB.Username,
-				cfg.DB.Database,
-				cfg.DB.SSLMode,
-				cfg.DB.Password,
-			)
-
-			db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-			if err != nil {
-				log.Fatal("Failed to connect to database:", err)
-			}
+			db := connectDB()
 
 			// Drop existing tables
 			if err := db.Migrator().DropTable(
@@ -46,10 +35,25 @@ func main() {
 				&models.Package{},
 				&models.DeploymentVersion{},
 				&models.PackageDiff{},
+				&models.PackageReleaseNote{},
 				&models.UserToken{},
 				&models.User{},
 				&models.LogReportDeploy{},
 				&models.LogReportDownload{},
+				&models.UserSession{},
+				&models.LoginAttempt{},
+				&models.RecoveryCode{},
+				&models.DeviceCode{},
+				&models.PasswordReset{},
+				&models.EmailVerification{},
+				&models.Invite{},
+				&models.Organization{},
+				&models.OrgMember{},
+				&models.AuditLog{},
+				&models.Webhook{},
+				&models.WebhookDelivery{},
+				&models.UploadSession{},
+				&models.Blob{},
 			); err != nil {
 				log.Fatal("Failed to drop tables:", err)
 			}
@@ -61,10 +65,25 @@ func main() {
 				&models.Package{},
 				&models.DeploymentVersion{},
 				&models.PackageDiff{},
+				&models.PackageReleaseNote{},
 				&models.UserToken{},
 				&models.User{},
 				&models.LogReportDeploy{},
 				&models.LogReportDownload{},
+				&models.UserSession{},
+				&models.LoginAttempt{},
+				&models.RecoveryCode{},
+				&models.DeviceCode{},
+				&models.PasswordReset{},
+				&models.EmailVerification{},
+				&models.Invite{},
+				&models.Organization{},
+				&models.OrgMember{},
+				&models.AuditLog{},
+				&models.Webhook{},
+				&models.WebhookDelivery{},
+				&models.UploadSession{},
+				&models.Blob{},
 			); err != nil {
 				log.Fatal("Failed to migrate database:", err)
 			}
@@ -77,22 +96,7 @@ func main() {
 		Use:   "seed",
 		Short: "Seed the database with initial data",
 		Run: func(cmd *cobra.Command, args []string) {
-			cfg := config.LoadConfig()
-
-			dsn := fmt.Sprintf(
-				"host=%s port=%s user=%s dbname=%s sslmode=%s password=%s",
-				cfg.DB.Host,
-				cfg.DB.Port,
-				cfg.DB.Username,
-				cfg.DB.Database,
-				cfg.DB.SSLMode,
-				cfg.DB.Password,
-			)
-
-			db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-			if err != nil {
-				log.Fatal("Failed to connect to database:", err)
-			}
+			db := connectDB()
 
 			// Add seed data
 			if err := seedDatabase(db); err != nil {
@@ -103,13 +107,169 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(migrateCmd, seedCmd)
+	var unlockIP string
+	var unlockCmd = &cobra.Command{
+		Use:   "unlock <account>",
+		Short: "Clear failed-login lockouts for an account (database login guard store only)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db := connectDB()
+			account := args[0]
+			var user models.User
+			if err := db.Where("email = ? OR username = ?", account, account).First(&user).Error; err == nil {
+				account = user.Email
+			}
+
+			guard := services.LoginGuard{Store: services.NewDBLoginAttemptStore(db)}
+			if err := guard.Unlock(services.AccountLoginKey(account)); err != nil {
+				log.Fatal("Failed to unlock account:", err)
+			}
+			if unlockIP != "" {
+				if err := guard.Unlock(services.IPLoginKey(unlockIP)); err != nil {
+					log.Fatal("Failed to unlock address:", err)
+				}
+			}
+
+			fmt.Println("Unlocked " + account)
+		},
+	}
+	unlockCmd.Flags().StringVar(&unlockIP, "ip", "", "also clear the lockout of this source address")
+
+	var adminCmd = &cobra.Command{
+		Use:       "admin grant|revoke <email>",
+		Short:     "Grant or revoke the server admin role",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"grant", "revoke"},
+		Run: func(cmd *cobra.Command, args []string) {
+			if args[0] != "grant" && args[0] != "revoke" {
+				log.Fatal("Unknown action " + args[0] + ", expected grant or revoke")
+			}
+			adminSvc := services.NewAdminService(connectDB())
+			if err := adminSvc.SetAdminByEmail(args[1], args[0] == "grant"); err != nil {
+				log.Fatal("Failed to update admin role:", err)
+			}
+			fmt.Println("Updated admin role of " + args[1])
+		},
+	}
+
+	var gcGrace time.Duration
+	var gcDelete bool
+	var gcCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "List storage objects and temp files nothing refers to, and delete them with --delete",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !cmd.Flags().Changed("grace") {
+				gcGrace = config.LoadConfig().Storage.GCGrace
+			}
+			gcSvc := services.NewGCService(connectDB())
+			plan, err := gcSvc.Plan(context.Background(), gcGrace)
+			if err != nil {
+				log.Fatal("Failed to plan garbage collection:", err)
+			}
+			for _, obj := range plan.Objects {
+				fmt.Printf("object  %10d  %s  %s\n", obj.Size, obj.ModTime.Format(time.RFC3339), obj.Key)
+			}
+			for _, f := range plan.TempFiles {
+				fmt.Printf("temp    %10d  %s  %s\n", f.Size, f.ModTime.Format(time.RFC3339), f.Key)
+			}
+			fmt.Printf("%d objects and %d temp files, %d bytes\n", len(plan.Objects), len(plan.TempFiles), plan.Bytes)
+			if !gcDelete {
+				fmt.Println("Dry run, pass --delete to remove them.")
+				return
+			}
+			kept, err := gcSvc.Collect(context.Background(), plan)
+			if err != nil {
+				log.Fatal("Failed to collect garbage:", err)
+			}
+			fmt.Printf("Deleted, except %d objects that are in use again.\n", kept)
+		},
+	}
+	gcCmd.Flags().DurationVar(&gcGrace, "grace", 0, "keep anything younger than this (default GC_GRACE)")
+	gcCmd.Flags().BoolVar(&gcDelete, "delete", false, "delete instead of only listing")
+
+	var storageCmd = &cobra.Command{
+		Use:   "storage",
+		Short: "Manage package storage",
+	}
+	var migrateFrom, migrateTo string
+	var storageMigrateCmd = &cobra.Command{
+		Use:   "migrate --from <type> --to <type>",
+		Short: "Copy all package and diff blobs to another storage backend and point packages at the copies",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, typ := range []string{migrateFrom, migrateTo} {
+				if typ != "local" && typ != "s3" && typ != "gcs" && typ != "azure" {
+					log.Fatal("Unknown storage type " + typ)
+				}
+			}
+			if migrateFrom == migrateTo {
+				log.Fatal("--from and --to must name different storage backends")
+			}
+			migration := services.StorageMigration{
+				DB:   connectDB(),
+				From: utils.NewStorageOfType(migrateFrom),
+				To:   utils.NewStorageOfType(migrateTo),
+				Progress: func(object, result string) {
+					fmt.Println(object + ": " + result)
+				},
+			}
+			result, err := migration.Run(context.Background())
+			if result != nil {
+				fmt.Printf("%d copied (%d bytes), %d already copied, %d missing, %d URLs not in %s\n",
+					result.Copied, result.Bytes, result.Skipped, result.Missing, result.Foreign, migrateFrom)
+			}
+			if err != nil {
+				log.Fatal("Migration stopped, run it again to resume: ", err)
+			}
+		},
+	}
+	storageMigrateCmd.Flags().StringVar(&migrateFrom, "from", "local", "storage type to copy from: local, s3, gcs or azure")
+	storageMigrateCmd.Flags().StringVar(&migrateTo, "to", "", "storage type to copy to: local, s3, gcs or azure")
+	storageMigrateCmd.MarkFlagRequired("to")
+
+	var keyBases []string
+	var storageKeysCmd = &cobra.Command{
+		Use:   "keys",
+		Short: "Store storage keys on packages and diffs that only have a download URL",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			converted, unknown, err := services.ConvertBlobURLs(connectDB(), utils.NewStorage(), keyBases)
+			fmt.Printf("%d URLs converted, %d not recognized\n", converted, unknown)
+			if err != nil {
+				log.Fatal("Failed to convert URLs: ", err)
+			}
+		},
+	}
+	storageKeysCmd.Flags().StringSliceVar(&keyBases, "base", nil, "earlier download URL to strip from URLs, may be repeated")
+	storageCmd.AddCommand(storageMigrateCmd, storageKeysCmd)
+
+	rootCmd.AddCommand(migrateCmd, seedCmd, unlockCmd, adminCmd, gcCmd, storageCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+func connectDB() *gorm.DB {
+	cfg := config.LoadConfig()
+
+	dsn := fmt.Sprintf(
+		"host=%s port=%s user=%s dbname=%s sslmode=%s password=%s",
+		cfg.DB.Host,
+		cfg.DB.Port,
+		cfg.DB.Username,
+		cfg.DB.Database,
+		cfg.DB.SSLMode,
+		cfg.DB.Password,
+	)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	return db
+}
+
 func seedDatabase(db *gorm.DB) error {
 	// Add your seed data here
 	db.Create(&models.App{
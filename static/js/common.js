@@ -1,16 +1,87 @@
+
 function getAccessToken() {
     return localStorage.getItem('auth');
 }
 
+function getRefreshToken() {
+    return localStorage.getItem('refresh');
+}
+
+function setTokens(results) {
+    localStorage.setItem('auth', results.tokens);
+    localStorage.setItem('refresh', results.refreshToken);
+}
+
+function clearTokens() {
+    localStorage.removeItem('auth');
+    localStorage.removeItem('refresh');
+}
+
 function ensureLogin() {
     if (!getAccessToken()) {
-        window.location.href = '/auth/login';
+        window.location.href = '/auth/login' + location.search;
+    }
+}
+
+// Exchanges the stored refresh token for a new token pair.
+function refreshAccessToken(done, fail) {
+    var refreshToken = getRefreshToken();
+    if (!refreshToken) {
+        fail();
+        return;
     }
+    $.ajax({
+        type: 'post',
+        data: { refreshToken: refreshToken },
+        url: '/auth/refresh',
+        dataType: 'json',
+        success: function (data) {
+            setTokens(data.results);
+            done();
+        },
+        error: fail
+    });
+}
+
+// Like $.ajax, but sends the access token and retries once after
+// refreshing it when the server answers 401.
+function authAjax(options) {
+    var error = options.error;
+    var send = function (retry) {
+        $.ajax($.extend({}, options, {
+            headers: $.extend({}, options.headers, { Authorization: 'Bearer ' + getAccessToken() }),
+            error: function (xhr, textStatus, errorThrown) {
+                if (xhr.status == 401 && retry) {
+                    refreshAccessToken(function () { send(false); }, function () {
+                        clearTokens();
+                        location.href = '/auth/login';
+                    });
+                } else if (error) {
+                    error(xhr, textStatus, errorThrown);
+                }
+            }
+        }));
+    };
+    send(true);
+}
+
+function endSession(url) {
+    authAjax({
+        type: 'post',
+        url: url,
+        complete: function () {
+            clearTokens();
+            location.href = '/auth/login';
+        }
+    });
 }
 
 function logout() {
-    localStorage.removeItem('auth');
-    location.href = '/auth/login';
+    endSession('/auth/logout');
+}
+
+function logoutEverywhere() {
+    endSession('/auth/logout/all');
 }
 
 function parseQuery() {
@@ -0,0 +1,22 @@
+var submit = false;
+$('#submitBtn').on('click', function () {
+    if (submit) return;
+    submit = true;
+    $.ajax({
+        type: 'post',
+        data: $('#form').serializeArray(),
+        url: $('#form').attr('action'),
+        dataType: 'json',
+        success: function (data) {
+            alert(data.message);
+            submit = false;
+            if (data.status == "OK") {
+                location.href = '/auth/login';
+            }
+        },
+        error: function (xhr, textStatus, errorThrown) {
+            alert(xhr.responseJSON ? xhr.responseJSON.message : errorThrown);
+            submit = false;
+        }
+    });
+});
\ No newline at end of file
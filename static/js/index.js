@@ -1,2 +1,3 @@
 ensureLogin();
-$('#logoutBtn').on('click', logout);
\ No newline at end of file
+$('#logoutBtn').on('click', logout);
+$('#logoutAllBtn').on('click', logoutEverywhere);
\ No newline at end of file
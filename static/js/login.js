@@ -1,6 +1,6 @@
 function onLoggedIn() {
     var query = parseQuery();
-    if (query.hostname) {
+    if (query.hostname || query.user_code) {
         location.href = '/tokens/' + location.search;
     } else {
         location.href = '/';
@@ -11,6 +11,9 @@ if (getAccessToken()) {
     onLoggedIn();
 }
 
+// Keep the CLI's hostname when signing in through the identity provider.
+$('#ssoBtn').attr('href', '/auth/oidc/login' + location.search);
+
 var submit = false;
 $('#submitBtn').on('click', function () {
     if (submit) return;
@@ -22,13 +25,32 @@ $('#submitBtn').on('click', function () {
         dataType: 'json',
         success: function (data) {
             if (data.status == "OK") {
-                localStorage.setItem('auth', data.results.tokens);
+                setTokens(data.results);
                 submit = false;
                 onLoggedIn();
+            } else if (data.status == "UNVERIFIED") {
+                submit = false;
+                if (confirm(data.message + '\n\nSend a new confirmation link?')) {
+                    $.post('/auth/verify/resend', { email: $('#inputEmail').val() }, function (resp) {
+                        alert(resp.message);
+                    }, 'json');
+                }
+            } else if (data.status == "OTP_REQUIRED") {
+                $('#otpGroup').show();
+                $('#inputOtp').focus();
+                submit = false;
             } else {
-                alert(data.message);
+                var message = data.message;
+                if (data.remainingAttempts !== undefined) {
+                    message += ' (' + data.remainingAttempts + ' attempts remaining)';
+                }
+                alert(message);
                 submit = false;
             }
+        },
+        error: function (xhr, textStatus, errorThrown) {
+            alert(xhr.responseJSON ? xhr.responseJSON.message : errorThrown);
+            submit = false;
         }
     });
 });
@@ -9,7 +9,7 @@ $('#submitBtn').on('click', function () {
         dataType: 'json',
         success: function (data) {
             if (data.status == "OK") {
-                alert("Registration successful, please log in");
+                alert(data.message);
                 location.href = '/auth/login?email=' + $('#inputEmail').val();
                 submit = false;
             } else {
@@ -15,12 +15,10 @@ $('#submitBtn').on('click', function () {
         description: "Login-" + time,
         isSession: true
     };
-    var accessToken = getAccessToken();
-    $.ajax({
+    authAjax({
         type: 'post',
         data: JSON.stringify(postParams),
         contentType: 'application/json',
-        headers: { Authorization: 'Bearer ' + accessToken },
         url: '/accessKeys',
         dataType: 'json',
         success: function (data) {
@@ -32,12 +30,48 @@ $('#submitBtn').on('click', function () {
         },
         error: function(xhr, textStatus, errorThrown) {
             submit = false;
-            if (errorThrown == 'Unauthorized') {
-                alert("please login again!");
-                location.href = '/auth/login';
-            } else {
-                alert(errorThrown);
-            }
+            alert(errorThrown);
         }
     });
-});
\ No newline at end of file
+});
+
+var deviceQuery = parseQuery();
+if (deviceQuery.user_code) {
+    $('#userCode').val(deviceQuery.user_code);
+}
+
+$('#deviceCheckBtn').on('click', function () {
+    authAjax({
+        type: 'get',
+        url: '/auth/device?user_code=' + encodeURIComponent($('#userCode').val()),
+        dataType: 'json',
+        success: function (data) {
+            $('#deviceInfo').text('"' + data.device.clientName + '" is requesting an access key for your account.').show();
+            $('#deviceCheckBtn').hide();
+            $('#deviceApproveBtn, #deviceDenyBtn').show();
+        },
+        error: function (xhr, textStatus, errorThrown) {
+            alert(xhr.responseJSON ? xhr.responseJSON.error : errorThrown);
+        }
+    });
+});
+
+function decideDevice(approve) {
+    authAjax({
+        type: 'post',
+        data: JSON.stringify({ userCode: $('#userCode').val(), approve: approve }),
+        contentType: 'application/json',
+        url: '/auth/device/approve',
+        dataType: 'json',
+        success: function () {
+            $('#deviceApproveBtn, #deviceDenyBtn, #deviceInfo').hide();
+            $('#deviceDone').text(approve ? 'Device approved, you can return to your terminal.' : 'Request denied.').show();
+        },
+        error: function (xhr, textStatus, errorThrown) {
+            alert(xhr.responseJSON ? xhr.responseJSON.error : errorThrown);
+        }
+    });
+}
+
+$('#deviceApproveBtn').on('click', function () { decideDevice(true); });
+$('#deviceDenyBtn').on('click', function () { decideDevice(false); });
\ No newline at end of file
@@ -0,0 +1,51 @@
+ensureLogin();
+
+var submit = false;
+function post(type, url, params, success) {
+    if (submit) return;
+    submit = true;
+    authAjax({
+        type: type,
+        data: JSON.stringify(params),
+        contentType: 'application/json;charset=utf-8',
+        url: url,
+        dataType: 'json',
+        success: function (data) {
+            submit = false;
+            if (data.status == "OK") {
+                success(data.results);
+            } else {
+                alert(data.message);
+            }
+        },
+        error: function (xhr, textStatus, errorThrown) {
+            submit = false;
+            alert(errorThrown);
+        }
+    });
+}
+
+$('#enrollBtn').on('click', function () {
+    post('post', '/users/twoFactor', {}, function (results) {
+        $('#enrollStep').hide();
+        $('#secret').val(results.secret);
+        new QRCode(document.getElementById('qrcode'), results.otpauthUri);
+        $('#confirmStep').show();
+    });
+});
+
+$('#confirmBtn').on('click', function () {
+    post('post', '/users/twoFactor/confirm', { code: $('#confirmCode').val() }, function (results) {
+        $('#confirmStep').hide();
+        $('#recoveryCodes').text(results.recoveryCodes.join('\n'));
+        $('#recoveryStep').show();
+    });
+});
+
+$('#disableBtn').on('click', function () {
+    var params = { password: $('#disablePassword').val(), code: $('#disableCode').val() };
+    post('delete', '/users/twoFactor', params, function () {
+        alert("two-factor authentication disabled");
+        location.href = '/';
+    });
+});
\ No newline at end of file
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+func NewMailer() Mailer {
+	if Config.Mail.SMTPHost == "" {
+		return NewLogMailer()
+	}
+	return NewSMTPMailer()
+}
+
+// mailTokenPattern matches the tokens of reset, invite and verification
+// links.
+var mailTokenPattern = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+// LogMailer implementation, for development setups without a mail server.
+// Tokens are redacted from the logged links, since anyone reading the logs
+// could use them; use a local SMTP sink to follow the links.
+type LogMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("Mail to %s: %s\n%s", to, subject, mailTokenPattern.ReplaceAllString(body, "${1}REDACTED"))
+	return nil
+}
+
+// SMTPMailer implementation. A local SMTP sink (MailHog, smtp4dev, ...) works
+// with just SMTP_HOST and SMTP_PORT.
+type SMTPMailer struct{}
+
+func NewSMTPMailer() Mailer {
+	return &SMTPMailer{}
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	cfg := Config.Mail
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM: %v", err)
+	}
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + from.String(),
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), auth, from.Address, []string{to}, []byte(msg))
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerRedactsTokens(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	body := "Open this link:\nhttp://127.0.0.1:8080/auth/password/reset?token=s3cr3t%2Bx&lang=en\n" +
+		"or http://127.0.0.1:8080/auth/verify?token=other\n"
+	if err := NewLogMailer().Send("dev@example.com", "Reset", body); err != nil {
+		t.Fatal(err)
+	}
+	logged := out.String()
+	if strings.Contains(logged, "s3cr3t") || strings.Contains(logged, "other") {
+		t.Fatalf("token logged: %s", logged)
+	}
+	if !strings.Contains(logged, "reset?token=REDACTED&lang=en") || !strings.Contains(logged, "verify?token=REDACTED") {
+		t.Fatalf("links not kept: %s", logged)
+	}
+}
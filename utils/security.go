@@ -4,6 +4,8 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 
 	"github.com/google/uuid"
@@ -18,6 +20,11 @@ func Md5(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+func Sha256(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
 func HashPassword(password string) string {
 	bytes, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes)
@@ -36,6 +43,16 @@ func RandToken(length int) string {
 	return uuidStr[:length]
 }
 
+// RandSecret returns a hex string built from n bytes of crypto/rand output,
+// for values that must not be guessable (refresh tokens, reset links).
+func RandSecret(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
 func BoolToUint8(b bool) uint8 {
 	if b {
 		return 1
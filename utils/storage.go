@@ -2,99 +2,216 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
-	"path/filepath"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"strings"
+	"time"
 )
 
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrSigningUnsupported is returned by SignedURL when the storage has no
+// credentials it can sign download URLs with.
+var ErrSigningUnsupported = errors.New("storage cannot sign download URLs")
+
+// ObjectInfo describes a stored object. SHA256 is the hex checksum when the
+// backend keeps one, otherwise empty.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	SHA256  string
+	ModTime time.Time
+}
+
+// Storage keeps package blobs under slash separated keys. Missing objects
+// are reported as ErrObjectNotFound.
 type Storage interface {
-	SaveFile(filePath, key string) error
-	GetFileURL(key string) string
-	UploadFile(filePath, key string) error
+	// Put stores r under key; size is the length of r, or -1 if unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// URL is the public download URL of key, as stored on packages.
+	URL(key string) string
+	// ObjectName is the name of key in the bucket or directory, with the
+	// configured prefix, as a CDN in front of the storage sees it.
+	ObjectName(key string) string
+	// SignedURL is a download URL for key that stops working after ttl.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
 }
 
-func NewStorage() Storage {
-	switch Config.Storage.Type {
-	case "s3":
-		return NewS3Storage()
-	default: // "local" or unrecognized falls back to local
-		return NewLocalStorage()
-	}
+// DirectUploader is implemented by storages clients can upload to without
+// going through the server.
+type DirectUploader interface {
+	// PresignPut returns a URL and the headers a client must PUT size bytes
+	// with; the store rejects a body whose SHA-256 (hex) differs.
+	PresignPut(ctx context.Context, key string, size int64, sha256 string, ttl time.Duration) (string, http.Header, error)
 }
 
-// LocalStorage implementation
-type LocalStorage struct{}
+// RangeReader is implemented by storages that can read part of an object.
+type RangeReader interface {
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
 
-func NewLocalStorage() Storage {
-	return &LocalStorage{}
+// ObjectCopier is implemented by storages that copy objects without the
+// data passing through the server.
+type ObjectCopier interface {
+	Copy(ctx context.Context, srcKey, dstKey string) error
 }
 
-func (s *LocalStorage) SaveFile(filePath, dest string) error {
-	err := os.MkdirAll(filepath.Dir(dest), 0755)
-	if err != nil {
-		return err
+// objectReaderAt reads an object of a RangeReader in blocks of at least
+// objectReadBlock bytes, keeping the last one, so archive/zip can read a
+// central directory without a request per record.
+type objectReaderAt struct {
+	ctx     context.Context
+	storage RangeReader
+	key     string
+	size    int64
+	off     int64
+	block   []byte
+}
+
+const objectReadBlock = 256 << 10
+
+// NewObjectReaderAt returns an io.ReaderAt over the size bytes of the
+// object under key.
+func NewObjectReaderAt(ctx context.Context, storage RangeReader, key string, size int64) io.ReaderAt {
+	return &objectReaderAt{ctx: ctx, storage: storage, key: key, size: size}
+}
+
+func (o *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) && off < o.size {
+		if off < o.off || off >= o.off+int64(len(o.block)) {
+			length := max(int64(len(p)-n), objectReadBlock)
+			length = min(length, o.size-off)
+			r, err := o.storage.GetRange(o.ctx, o.key, off, length)
+			if err != nil {
+				return n, err
+			}
+			block := make([]byte, length)
+			_, err = io.ReadFull(r, block)
+			r.Close()
+			if err != nil {
+				return n, err
+			}
+			o.off, o.block = off, block
+		}
+		c := copy(p[n:], o.block[off-o.off:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
 	}
-	return os.Rename(filePath, dest)
+	return n, nil
 }
 
-func (s *LocalStorage) GetFileURL(fileName string) string {
-	return Config.Storage.Local.DownloadUrl + "/" + filepath.Base(fileName)
+func NewStorage() Storage {
+	return NewStorageOfType(Config.Storage.Type)
 }
 
-func (s *LocalStorage) UploadFile(filePath, key string) error {
-	return s.SaveFile(filePath, Config.Storage.Local.StorageDir+"/"+key)
+// NewStorageOfType returns the backend named typ, configured like it would
+// be with STORAGE_TYPE=typ.
+func NewStorageOfType(typ string) Storage {
+	switch typ {
+	case "s3":
+		return NewS3Storage()
+	case "gcs":
+		return NewGCSStorage()
+	case "azure":
+		return NewAzureStorage()
+	default: // "local" or unrecognized falls back to local
+		return NewLocalStorage()
+	}
 }
 
-// S3Storage implementation
-type S3Storage struct {
-	client *s3.Client
+// PutFile stores the file at filePath under key.
+func PutFile(ctx context.Context, storage Storage, filePath, key string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return storage.Put(ctx, key, f, info.Size())
 }
 
-func NewS3Storage() Storage {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(Config.Storage.S3.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			Config.Storage.S3.AccessKeyID,
-			Config.Storage.S3.SecretAccessKey,
-			"",
-		)),
-	)
+// GetFile copies the object under key to filePath.
+func GetFile(ctx context.Context, storage Storage, key, filePath string) error {
+	r, err := storage.Get(ctx, key)
 	if err != nil {
-		panic("Failed to load AWS config: " + err.Error())
+		return err
 	}
+	defer r.Close()
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	return &S3Storage{
-		client: s3.NewFromConfig(cfg),
+// PublicURL is the permanent download URL of key, on CDN_BASE_URL when set.
+// The CDN is expected to serve the storage root, prefix included.
+func PublicURL(storage Storage, key string) string {
+	if Config.Storage.CDNBaseURL != "" {
+		return strings.TrimSuffix(Config.Storage.CDNBaseURL, "/") + "/" + storage.ObjectName(key)
 	}
+	return storage.URL(key)
 }
 
-func (s *S3Storage) SaveFile(filePath, key string) error {
-	return s.UploadFile(filePath, key)
+// SignDownloads reports whether download URLs are signed. A CDN takes
+// precedence: signatures of the storage are bound to its own host, so with
+// CDN_BASE_URL set, DOWNLOAD_URL_TTL is ignored and access to the bundles
+// is up to the CDN.
+func SignDownloads() bool {
+	return Config.Storage.DownloadURLTTL > 0 && Config.Storage.CDNBaseURL == ""
 }
 
-func (s *S3Storage) GetFileURL(key string) string {
-	if Config.Storage.S3.DownloadUrl != "" {
-		return Config.Storage.S3.DownloadUrl + "/" + key
+// DownloadURL is the URL clients download key from: a signed one while
+// download URLs expire, the public one otherwise. When signing fails, no URL
+// is handed out rather than a permanent one.
+func DownloadURL(ctx context.Context, storage Storage, key string) (string, error) {
+	if SignDownloads() {
+		return storage.SignedURL(ctx, key, Config.Storage.DownloadURLTTL)
 	}
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", Config.Storage.S3.BucketName, Config.Storage.S3.Region, key)
+	return PublicURL(storage, key), nil
 }
 
-func (s *S3Storage) UploadFile(filePath, key string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
+// CheckDownloadSigning fails when download URLs are to be signed and
+// storage cannot sign them, so the server refuses to start instead of
+// failing every update check.
+func CheckDownloadSigning(ctx context.Context, storage Storage) error {
+	if !SignDownloads() {
+		return nil
+	}
+	if _, err := storage.SignedURL(ctx, "blobs/signing-check", Config.Storage.DownloadURLTTL); err != nil {
+		return fmt.Errorf("DOWNLOAD_URL_TTL is set but the storage cannot sign download URLs: %w", err)
+	}
+	return nil
+}
+
+// KeyFromURL returns the key of an object from its URL, or "" if url was
+// not handed out by storage.
+func KeyFromURL(storage Storage, url string) string {
+	base := storage.URL("")
+	if !strings.HasPrefix(url, base) || len(url) == len(base) {
+		return ""
 	}
-	defer file.Close()
-
-	_, err = s.client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(Config.Storage.S3.BucketName),
-		Key:    aws.String(key),
-		Body:   file,
-	})
-	return err
+	return strings.TrimPrefix(url, base)
 }
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+)
+
+// AzureStorage keeps objects as block blobs in an Azure storage container,
+// or in the Azurite emulator when Config.Storage.Azure.Endpoint points at it.
+type AzureStorage struct {
+	client *container.Client
+	// base is the container URL without any SAS token.
+	base   string
+	prefix string
+}
+
+func NewAzureStorage() Storage {
+	cfg := Config.Storage.Azure
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://" + cfg.AccountName + ".blob.core.windows.net"
+	}
+	containerURL := endpoint + "/" + cfg.Container
+
+	var client *container.Client
+	var err error
+	switch {
+	case cfg.ConnectionString != "":
+		client, err = container.NewClientFromConnectionString(cfg.ConnectionString, cfg.Container, nil)
+	case cfg.AccountKey != "":
+		var cred *container.SharedKeyCredential
+		if cred, err = container.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey); err == nil {
+			client, err = container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+		}
+	case cfg.SASToken != "":
+		client, err = container.NewClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+	default:
+		err = errors.New("set AZURE_STORAGE_CONNECTION_STRING, AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN")
+	}
+	if err != nil {
+		panic("Failed to create Azure storage client: " + err.Error())
+	}
+
+	base, _, _ := strings.Cut(client.URL(), "?")
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &AzureStorage{client: client, base: strings.TrimSuffix(base, "/"), prefix: prefix}
+}
+
+func (s *AzureStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := s.client.NewBlockBlobClient(s.prefix+key).UploadStream(ctx, r, nil)
+	return err
+}
+
+func (s *AzureStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.NewBlobClient(s.prefix+key).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, azureError(err)
+	}
+	return resp.Body, nil
+}
+
+// Stat leaves SHA256 empty, Azure only keeps an MD5 of the content.
+func (s *AzureStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	props, err := s.client.NewBlobClient(s.prefix+key).GetProperties(ctx, nil)
+	if err != nil {
+		return ObjectInfo{}, azureError(err)
+	}
+	return ObjectInfo{Key: key, Size: deref(props.ContentLength), ModTime: deref(props.LastModified)}, nil
+}
+
+func (s *AzureStorage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.NewBlobClient(s.prefix+key).Delete(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *AzureStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	pages := s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: to.Ptr(s.prefix + prefix)})
+	for pages.More() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Segment.BlobItems {
+			info := ObjectInfo{Key: strings.TrimPrefix(deref(blob.Name), s.prefix)}
+			if blob.Properties != nil {
+				info.Size = deref(blob.Properties.ContentLength)
+				info.ModTime = deref(blob.Properties.LastModified)
+			}
+			objects = append(objects, info)
+		}
+	}
+	return objects, nil
+}
+
+func (s *AzureStorage) ObjectName(key string) string {
+	return s.prefix + key
+}
+
+func (s *AzureStorage) URL(key string) string {
+	if Config.Storage.Azure.DownloadUrl != "" {
+		return Config.Storage.Azure.DownloadUrl + "/" + s.prefix + key
+	}
+	return s.base + "/" + s.prefix + key
+}
+
+// SignedURL signs a read-only SAS with the account key. With only a SAS
+// token configured there is no key to sign with, and the configured token
+// is never handed out in its place.
+func (s *AzureStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	signed, err := s.client.NewBlobClient(s.prefix+key).GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(ttl), nil)
+	if errors.Is(err, bloberror.MissingSharedKeyCredential) {
+		return "", ErrSigningUnsupported
+	}
+	return signed, err
+}
+
+// azureError maps the "no such blob" error of Azure to ErrObjectNotFound.
+func azureError(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
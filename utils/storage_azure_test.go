@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// The Azure tests run against Azurite from docker-compose.yml, with its
+// well-known development account:
+//
+//	AZURE_STORAGE_CONTAINER=codepush AZURE_STORAGE_CONNECTION_STRING="DefaultEndpointsProtocol=http;\
+//	AccountName=devstoreaccount1;AccountKey=<key from the README>;\
+//	BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1" go test ./utils
+func TestAzureStorage(t *testing.T) {
+	if os.Getenv("AZURE_STORAGE_CONNECTION_STRING") == "" || os.Getenv("AZURE_STORAGE_CONTAINER") == "" {
+		t.Skip("set AZURE_STORAGE_CONNECTION_STRING and AZURE_STORAGE_CONTAINER to test against Azurite")
+	}
+	storage := NewAzureStorage().(*AzureStorage)
+	if _, err := storage.client.Create(context.Background(), nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		t.Fatalf("create container: %v", err)
+	}
+	testStorage(t, storage, signedURLServed)
+}
+
+func TestAzureSASTokenNotSigned(t *testing.T) {
+	saved := Config.Storage.Azure
+	t.Cleanup(func() { Config.Storage.Azure = saved })
+	Config.Storage.Azure.ConnectionString = ""
+	Config.Storage.Azure.AccountKey = ""
+	Config.Storage.Azure.AccountName = "account"
+	Config.Storage.Azure.Container = "codepush"
+	Config.Storage.Azure.SASToken = "sv=2024-01-01&sig=secret"
+
+	storage := NewAzureStorage()
+	if signed, err := storage.SignedURL(context.Background(), "blobs/abc", time.Minute); !errors.Is(err, ErrSigningUnsupported) {
+		t.Fatalf("SignedURL: got %q, %v, want ErrSigningUnsupported", signed, err)
+	}
+	if url := storage.URL("blobs/abc"); strings.Contains(url, "secret") {
+		t.Fatalf("URL exposes the SAS token: %s", url)
+	}
+}
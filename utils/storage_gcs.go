@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSStorage keeps objects in a Google Cloud Storage bucket. Setting
+// STORAGE_EMULATOR_HOST points it at an emulator such as fake-gcs-server.
+type GCSStorage struct {
+	bucket *storage.BucketHandle
+	name   string
+	prefix string
+}
+
+func NewGCSStorage() Storage {
+	var opts []option.ClientOption
+	if Config.Storage.GCS.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(Config.Storage.GCS.CredentialsFile))
+	}
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		panic("Failed to create GCS client: " + err.Error())
+	}
+
+	prefix := strings.Trim(Config.Storage.GCS.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &GCSStorage{
+		bucket: client.Bucket(Config.Storage.GCS.BucketName),
+		name:   Config.Storage.GCS.BucketName,
+		prefix: prefix,
+	}
+}
+
+func (s *GCSStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	// Cancelling the context is the only way to abandon a partial write.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := s.bucket.Object(s.prefix + key).NewWriter(ctx)
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *GCSStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := s.bucket.Object(s.prefix + key).NewReader(ctx)
+	if err != nil {
+		return nil, gcsError(err)
+	}
+	return r, nil
+}
+
+// Stat leaves SHA256 empty, GCS only keeps MD5 and CRC32C checksums.
+func (s *GCSStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	attrs, err := s.bucket.Object(s.prefix + key).Attrs(ctx)
+	if err != nil {
+		return ObjectInfo{}, gcsError(err)
+	}
+	return ObjectInfo{Key: key, Size: attrs.Size, ModTime: attrs.Updated}, nil
+}
+
+func (s *GCSStorage) Delete(ctx context.Context, key string) error {
+	err := s.bucket.Object(s.prefix + key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *GCSStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	it := s.bucket.Objects(ctx, &storage.Query{Prefix: s.prefix + prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:     strings.TrimPrefix(attrs.Name, s.prefix),
+			Size:    attrs.Size,
+			ModTime: attrs.Updated,
+		})
+	}
+}
+
+func (s *GCSStorage) ObjectName(key string) string {
+	return s.prefix + key
+}
+
+func (s *GCSStorage) URL(key string) string {
+	key = s.prefix + key
+	if Config.Storage.GCS.DownloadUrl != "" {
+		return Config.Storage.GCS.DownloadUrl + "/" + key
+	}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimSuffix(host, "/") + "/" + s.name + "/" + key
+	}
+	return "https://storage.googleapis.com/" + s.name + "/" + key
+}
+
+// SignedURL needs credentials that can sign: a service account key, or the
+// iam.serviceAccounts.signBlob permission for the attached service account.
+func (s *GCSStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return s.bucket.SignedURL(s.prefix+key, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+		Scheme:  storage.SigningSchemeV4,
+	})
+}
+
+// gcsError maps the "no such object" error of GCS to ErrObjectNotFound.
+func gcsError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrObjectNotFound
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+// The GCS tests run against fake-gcs-server from docker-compose.yml:
+//
+//	STORAGE_EMULATOR_HOST=localhost:4443 GCS_BUCKET_NAME=codepush go test ./utils
+//
+// The client does not authenticate against the emulator, so it has nothing
+// to sign download URLs with.
+func TestGCSStorage(t *testing.T) {
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" || os.Getenv("GCS_BUCKET_NAME") == "" {
+		t.Skip("set STORAGE_EMULATOR_HOST and GCS_BUCKET_NAME to test against fake-gcs-server")
+	}
+	storage := NewGCSStorage().(*GCSStorage)
+	var exists *googleapi.Error
+	if err := storage.bucket.Create(context.Background(), "test", nil); err != nil &&
+		!(errors.As(err, &exists) && exists.Code == http.StatusConflict) {
+		t.Fatalf("create bucket: %v", err)
+	}
+	testStorage(t, storage, signedURLFails)
+}
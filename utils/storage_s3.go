@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Storage keeps objects in an S3 bucket, or in any store speaking the S3
+// API (MinIO, Ceph, R2, Wasabi) when Config.Storage.S3.Endpoint is set.
+type S3Storage struct {
+	client   *s3.Client
+	bucket   string
+	prefix   string
+	sse      types.ServerSideEncryption
+	kmsKeyID string
+}
+
+func NewS3Storage() Storage {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(Config.Storage.S3.Region)}
+	if Config.Storage.S3.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			Config.Storage.S3.AccessKeyID,
+			Config.Storage.S3.SecretAccessKey,
+			Config.Storage.S3.SessionToken,
+		)))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		panic("Failed to load AWS config: " + err.Error())
+	}
+
+	sse := types.ServerSideEncryption(Config.Storage.S3.SSE)
+	switch sse {
+	case "", types.ServerSideEncryptionAes256, types.ServerSideEncryptionAwsKms:
+	default:
+		panic("Unsupported AWS_S3_SSE " + Config.Storage.S3.SSE)
+	}
+
+	prefix := strings.Trim(Config.Storage.S3.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Storage{
+		client: s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if Config.Storage.S3.Endpoint != "" {
+				o.BaseEndpoint = aws.String(Config.Storage.S3.Endpoint)
+			}
+			o.UsePathStyle = Config.Storage.S3.PathStyle
+			// Only send checksums we ask for; presigned URLs must not carry
+			// one computed over an empty body.
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		}),
+		bucket:   Config.Storage.S3.BucketName,
+		prefix:   prefix,
+		sse:      sse,
+		kmsKeyID: Config.Storage.S3.SSEKMSKeyID,
+	}
+}
+
+func (s *S3Storage) key(key string) *string {
+	return aws.String(s.prefix + key)
+}
+
+// encryption fills the server-side encryption fields of a write request.
+func (s *S3Storage) encryption() (types.ServerSideEncryption, *string) {
+	if s.sse == types.ServerSideEncryptionAwsKms && s.kmsKeyID != "" {
+		return s.sse, aws.String(s.kmsKeyID)
+	}
+	return s.sse, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		// S3 needs the length up front, spool the stream to find it.
+		tmp, err := os.CreateTemp(Config.Common.TempDir, "s3-put-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, r); err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = tmp
+	}
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           s.key(key),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(key),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          s.key(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return ObjectInfo{}, s3Error(err)
+	}
+	info := ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), ModTime: aws.ToTime(out.LastModified)}
+	if out.ChecksumSHA256 != nil {
+		if sum, err := base64.StdEncoding.DecodeString(*out.ChecksumSHA256); err == nil {
+			info.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	return info, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(key),
+	})
+	return err
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: s.key(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:     strings.TrimPrefix(aws.ToString(obj.Key), s.prefix),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (s *S3Storage) ObjectName(key string) string {
+	return s.prefix + key
+}
+
+func (s *S3Storage) URL(key string) string {
+	key = s.prefix + key
+	if Config.Storage.S3.DownloadUrl != "" {
+		return Config.Storage.S3.DownloadUrl + "/" + key
+	}
+	if endpoint := strings.TrimSuffix(Config.Storage.S3.Endpoint, "/"); endpoint != "" {
+		if Config.Storage.S3.PathStyle {
+			return endpoint + "/" + s.bucket + "/" + key
+		}
+		if scheme, host, ok := strings.Cut(endpoint, "://"); ok {
+			return scheme + "://" + s.bucket + "." + host + "/" + key
+		}
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, Config.Storage.S3.Region, key)
+}
+
+func (s *S3Storage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Storage) PresignPut(ctx context.Context, key string, size int64, sha256 string, ttl time.Duration) (string, http.Header, error) {
+	sum, err := hex.DecodeString(sha256)
+	if err != nil {
+		return "", nil, err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:         aws.String(s.bucket),
+		Key:            s.key(key),
+		ContentLength:  aws.Int64(size),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum)),
+	}
+	// The encryption headers are signed and handed to the client.
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+	req, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, input, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", nil, err
+	}
+	headers := http.Header{}
+	for name, values := range req.SignedHeader {
+		if name != "Host" {
+			headers[name] = values
+		}
+	}
+	return req.URL, headers, nil
+}
+
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    s.key(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return out.Body, nil
+}
+
+// Copy copies within the bucket; objects of up to 5 GB, far above any
+// package, can be copied in one request.
+func (s *S3Storage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               s.key(dstKey),
+		CopySource:        aws.String(s.bucket + "/" + url.PathEscape(s.prefix+srcKey)),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+	_, err := s.client.CopyObject(ctx, input)
+	return s3Error(err)
+}
+
+// s3Error maps the "no such object" errors of S3 to ErrObjectNotFound.
+func s3Error(err error) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return ErrObjectNotFound
+	}
+	return err
+}
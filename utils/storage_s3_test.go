@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// The S3 tests run against a real bucket, e.g. the minio service from
+// docker-compose.yml:
+//
+//	AWS_ENDPOINT=http://localhost:9000 AWS_BUCKET_NAME=codepush \
+//	AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin go test ./utils
+func s3TestStorage(t *testing.T) *S3Storage {
+	if os.Getenv("AWS_ENDPOINT") == "" || os.Getenv("AWS_BUCKET_NAME") == "" {
+		t.Skip("set AWS_ENDPOINT and AWS_BUCKET_NAME to test against an S3 compatible store")
+	}
+	return NewS3Storage().(*S3Storage)
+}
+
+func TestS3Storage(t *testing.T) {
+	testStorage(t, s3TestStorage(t), signedURLServed)
+}
+
+func TestS3DirectUpload(t *testing.T) {
+	storage := s3TestStorage(t)
+	ctx := context.Background()
+	body := []byte("direct upload")
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+
+	put := func(key string, content []byte) int {
+		uploadURL, headers, err := storage.PresignPut(ctx, key, int64(len(body)), checksum, time.Minute)
+		if err != nil {
+			t.Fatalf("PresignPut: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = headers
+		req.ContentLength = int64(len(content))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	key := "test-" + RandSecret(6) + "/staging/upload"
+	t.Cleanup(func() { storage.Delete(ctx, key) })
+	if status := put(key, body); status != http.StatusOK {
+		t.Fatalf("PUT with the announced content: %d", status)
+	}
+	info, err := storage.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(body)) || (info.SHA256 != "" && info.SHA256 != checksum) {
+		t.Fatalf("Stat: got %+v, want size %d and SHA-256 %s", info, len(body), checksum)
+	}
+
+	r, err := storage.GetRange(ctx, key, 7, 6)
+	if err != nil {
+		t.Fatalf("GetRange: %v", err)
+	}
+	part, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(part) != "upload" {
+		t.Fatalf("GetRange(7, 6) = %q, %v", part, err)
+	}
+
+	copied := "test-" + RandSecret(6) + "/blobs/" + checksum
+	t.Cleanup(func() { storage.Delete(ctx, copied) })
+	if err := storage.Copy(ctx, key, copied); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if info, err := storage.Stat(ctx, copied); err != nil || info.Size != int64(len(body)) || (info.SHA256 != "" && info.SHA256 != checksum) {
+		t.Fatalf("Stat of the copy: got %+v, %v", info, err)
+	}
+
+	corrupt := "test-" + RandSecret(6) + "/staging/corrupt"
+	t.Cleanup(func() { storage.Delete(ctx, corrupt) })
+	if status := put(corrupt, []byte("DIRECT UPLOAD")); status == http.StatusOK {
+		t.Fatal("PUT of content with another checksum was accepted")
+	}
+}
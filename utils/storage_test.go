@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// How testStorage checks SignedURL.
+const (
+	signedURLServed  = iota // the signed URL is downloaded over HTTP
+	signedURLCreated        // the signed URL is only created, nothing serves it in the test
+	signedURLFails          // the storage has no credentials to sign with
+)
+
+// testStorage runs the Storage contract against storage, under a key prefix
+// unique to the run so a shared bucket or emulator can be reused.
+func testStorage(t *testing.T, storage Storage, signing int) {
+	ctx := context.Background()
+	prefix := "test-" + RandSecret(6) + "/"
+	key := prefix + "blobs/package.zip"
+	content := "package content"
+
+	if _, err := storage.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Stat of a missing object: got %v, want ErrObjectNotFound", err)
+	}
+	if _, err := storage.Get(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Get of a missing object: got %v, want ErrObjectNotFound", err)
+	}
+
+	if err := storage.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := storage.Put(ctx, prefix+"other", strings.NewReader("x"), -1); err != nil {
+		t.Fatalf("Put of unknown size: %v", err)
+	}
+	t.Cleanup(func() {
+		storage.Delete(ctx, key)
+		storage.Delete(ctx, prefix+"other")
+	})
+
+	if got := readObject(t, storage, key); got != content {
+		t.Fatalf("Get: got %q, want %q", got, content)
+	}
+	info, err := storage.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Key != key || info.Size != int64(len(content)) || info.ModTime.IsZero() {
+		t.Fatalf("Stat: got %+v", info)
+	}
+
+	objects, err := storage.List(ctx, prefix+"blobs/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != key || objects[0].Size != int64(len(content)) {
+		t.Fatalf("List: got %+v, want only %s", objects, key)
+	}
+
+	if got := KeyFromURL(storage, storage.URL(key)); got != key {
+		t.Fatalf("KeyFromURL(URL(%q)) = %q", key, got)
+	}
+	signed, err := storage.SignedURL(ctx, key, time.Minute)
+	switch {
+	case signing == signedURLFails:
+		if err == nil {
+			t.Fatalf("SignedURL without credentials: got %s, want an error", signed)
+		}
+	case err != nil:
+		t.Fatalf("SignedURL: %v", err)
+	case signing == signedURLServed:
+		if got := fetchURL(t, signed); got != content {
+			t.Fatalf("signed URL served %q, want %q", got, content)
+		}
+	}
+
+	if err := storage.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := storage.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing object: %v", err)
+	}
+	if _, err := storage.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Stat after Delete: got %v, want ErrObjectNotFound", err)
+	}
+}
+
+func readObject(t *testing.T, storage Storage, key string) string {
+	r, err := storage.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	return string(b)
+}
+
+func fetchURL(t *testing.T, rawURL string) string {
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s %s", rawURL, resp.Status, b)
+	}
+	return string(b)
+}
+
+func TestLocalStorage(t *testing.T) {
+	Config.Storage.Local.StorageDir = t.TempDir()
+	storage := NewLocalStorage()
+	testStorage(t, storage, signedURLCreated)
+
+	ctx := context.Background()
+	if err := storage.Put(ctx, "../escape", strings.NewReader("x"), 1); err == nil {
+		t.Fatal("Put accepted a key outside the storage dir")
+	}
+	signed, err := storage.SignedURL(ctx, "blobs/abc", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if !VerifyLocalDownload("blobs/abc", q.Get("expires"), q.Get("signature")) {
+		t.Fatal("signed URL does not verify")
+	}
+	if VerifyLocalDownload("blobs/other", q.Get("expires"), q.Get("signature")) {
+		t.Fatal("signature verified for another key")
+	}
+}
+
+// bytesRanges serves ranges of content and counts the requests.
+type bytesRanges struct {
+	content  []byte
+	requests int
+}
+
+func (b *bytesRanges) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	b.requests++
+	return io.NopCloser(bytes.NewReader(b.content[offset : offset+length])), nil
+}
+
+func TestObjectReaderAt(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), objectReadBlock/5)
+	ranges := &bytesRanges{content: content}
+	r := NewObjectReaderAt(context.Background(), ranges, "key", int64(len(content)))
+
+	for _, tc := range []struct {
+		off  int64
+		size int
+	}{{0, 10}, {5, 100}, {objectReadBlock - 3, 10}, {int64(len(content)) - 4, 4}, {17, objectReadBlock + 20}} {
+		p := make([]byte, tc.size)
+		if n, err := r.ReadAt(p, tc.off); err != nil || n != tc.size || !bytes.Equal(p, content[tc.off:tc.off+int64(tc.size)]) {
+			t.Fatalf("ReadAt(%d bytes at %d) = %d, %v", tc.size, tc.off, n, err)
+		}
+	}
+	if ranges.requests > 5 {
+		t.Fatalf("%d range requests, want reads within a block to be served from it", ranges.requests)
+	}
+	p := make([]byte, 10)
+	if n, err := r.ReadAt(p, int64(len(content))-4); n != 4 || err != io.EOF {
+		t.Fatalf("ReadAt past the end = %d, %v, want 4, io.EOF", n, err)
+	}
+}
+
+// unsignedStorage is a local storage without credentials to sign with.
+type unsignedStorage struct{ *LocalStorage }
+
+func (unsignedStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "", ErrSigningUnsupported
+}
+
+func TestDownloadURL(t *testing.T) {
+	saved := Config.Storage
+	t.Cleanup(func() { Config.Storage = saved })
+	Config.Storage.Local.StorageDir = t.TempDir()
+	Config.Storage.CDNBaseURL = ""
+	ctx := context.Background()
+	local := NewLocalStorage().(*LocalStorage)
+
+	Config.Storage.DownloadURLTTL = time.Hour
+	if u, err := DownloadURL(ctx, local, "blobs/abc"); err != nil || !strings.Contains(u, "signature=") {
+		t.Fatalf("DownloadURL with a TTL = %q, %v, want a signed URL", u, err)
+	}
+	if u, err := DownloadURL(ctx, unsignedStorage{local}, "blobs/abc"); err == nil {
+		t.Fatalf("DownloadURL of a storage that cannot sign = %q, want an error", u)
+	}
+	if err := CheckDownloadSigning(ctx, unsignedStorage{local}); !errors.Is(err, ErrSigningUnsupported) {
+		t.Fatalf("CheckDownloadSigning = %v, want ErrSigningUnsupported", err)
+	}
+
+	Config.Storage.DownloadURLTTL = 0
+	if u, err := DownloadURL(ctx, unsignedStorage{local}, "blobs/abc"); err != nil || u != local.URL("blobs/abc") {
+		t.Fatalf("DownloadURL without a TTL = %q, %v, want the public URL", u, err)
+	}
+	if err := CheckDownloadSigning(ctx, unsignedStorage{local}); err != nil {
+		t.Fatalf("CheckDownloadSigning without a TTL = %v", err)
+	}
+}
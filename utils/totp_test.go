@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA-1 test vectors of RFC 6238 Appendix B, cut to the last 6 of their 8 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step := TOTPStep(time.Unix(v.unix, 0))
+		for _, tc := range []struct {
+			name  string
+			delta int64
+			ok    bool
+		}{
+			{"same step", 0, true},
+			{"one step later", 1, true},
+			{"one step earlier", -1, true},
+			{"two steps later", 2, false},
+			{"two steps earlier", -2, false},
+		} {
+			// Start of the step, which for 59 is before 1970 two steps earlier.
+			at := time.Unix((step+tc.delta)*totpPeriod, 0)
+			got, ok := VerifyTOTP(rfc6238Secret, v.code, at)
+			if ok != tc.ok {
+				t.Errorf("VerifyTOTP(%s) at %d, %s: ok = %v, want %v", v.code, v.unix, tc.name, ok, tc.ok)
+			}
+			if ok && got != step {
+				t.Errorf("VerifyTOTP(%s) at %d, %s: step = %d, want %d", v.code, v.unix, tc.name, got, step)
+			}
+		}
+	}
+
+	code := rfc6238Vectors[0].code
+	at := time.Unix(rfc6238Vectors[0].unix, 0)
+	for _, bad := range []string{"", "28708", "2870820", "287083"} {
+		if _, ok := VerifyTOTP(rfc6238Secret, bad, at); ok {
+			t.Errorf("VerifyTOTP accepted %q", bad)
+		}
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, "287 082", at); !ok {
+		t.Errorf("VerifyTOTP refused %q with a space", code)
+	}
+}